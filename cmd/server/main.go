@@ -1,28 +1,80 @@
-package main
-
-import (
-	"log"
-	"net/http"
-	"os"
-
-	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/websocket"
-	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
-)
-
-func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	gameServer := server.New()
-	wsHandler := websocket.New(gameServer)
-
-	http.HandleFunc("/ws", wsHandler.Handle)
-
-	log.Printf("Word Search server listening on :%s\n", port)
-
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatal("server failed:", err)
-	}
-}
\ No newline at end of file
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/admin"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/logging"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/metrics"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/websocket"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
+)
+
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(cfg.Logging, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	gameServer, err := server.New(cfg)
+	if err != nil {
+		fatal("failed to start", err)
+	}
+	gameServer.StartIdleReaper(cfg.Connection.IdleTimeout.Duration)
+	gameServer.StartJanitor()
+	wsHandler, err := websocket.New(gameServer, cfg)
+	if err != nil {
+		fatal("failed to start", err)
+	}
+
+	http.HandleFunc("/ws", wsHandler.Handle)
+	http.Handle("/metrics", metrics.Handler())
+	http.HandleFunc("/healthz", admin.Healthz)
+	http.HandleFunc("/readyz", admin.Readyz(gameServer))
+	http.Handle("/admin/", admin.New(gameServer, cfg.Admin.Token))
+
+	httpServer := &http.Server{Addr: cfg.ListenAddr}
+
+	go func() {
+		slog.Info("Word Search server listening", "addr", cfg.ListenAddr)
+
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("server failed", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	slog.Info("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout.Duration)
+	defer cancel()
+
+	// stop accepting connections first, websockets are hijacked and left to the game server
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("http shutdown", "error", err)
+	}
+	gameServer.Shutdown(ctx, cfg.Shutdown.WaitForGames)
+
+	slog.Info("shutdown complete")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
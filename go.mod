@@ -1,6 +1,6 @@
 module github.com/Gexff/word-search-1v1-go-websocket-server
 
-go 1.13
+go 1.21
 
 require (
 	github.com/google/uuid v1.6.0
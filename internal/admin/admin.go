@@ -0,0 +1,122 @@
+// Package admin serves the health checks and the operator endpoints
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
+)
+
+// Healthz answers as long as the process is serving HTTP
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// Readyz fails while the server can't take players, e.g. during shutdown
+func Readyz(s *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	}
+}
+
+// Handler serves everything under /admin/:
+//
+//	GET  /admin/rooms
+//	POST /admin/rooms/{id}/close
+//	POST /admin/players/{id}/kick
+//	GET  /admin/matches[?flagged=true]
+//
+// close and kick take an optional "reason" form value.
+type Handler struct {
+	server *server.Server
+	token string
+}
+
+func New(s *server.Server, token string) *Handler {
+	return &Handler{server: s, token: token}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/"), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "rooms":
+		h.get(w, r, func() interface{} { return h.server.Rooms() })
+	case len(parts) == 1 && parts[0] == "matches":
+		flagged := r.URL.Query().Get("flagged") == "true"
+		h.get(w, r, func() interface{} { return h.server.Matches(flagged) })
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "close":
+		h.post(w, r, "room closed by an operator", func(reason string) error { return h.server.CloseRoom(parts[1], reason) })
+	case len(parts) == 3 && parts[0] == "players" && parts[2] == "kick":
+		h.post(w, r, "kicked by an operator", func(reason string) error { return h.server.KickPlayer(parts[1], reason) })
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, fn func() interface{}) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, fn())
+}
+
+func (h *Handler) post(w http.ResponseWriter, r *http.Request, defaultReason string, fn func(reason string) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reason := r.FormValue("reason")
+	if reason == "" {
+		reason = defaultReason
+	}
+	// ends up in a close frame, which is capped at 125 bytes of valid UTF-8
+	reason = strings.ToValidUTF8(reason, "")
+	if len(reason) > 120 {
+		cut := 120
+		for !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut]
+	}
+
+	slog.Info("admin action", "path", r.URL.Path, "remote_addr", r.RemoteAddr, "reason", reason)
+	switch err := fn(reason); err {
+	case nil:
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case server.ErrRoomNotFound, server.ErrPlayerNotFound:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
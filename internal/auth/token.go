@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed token")
+	ErrSignature = errors.New("invalid token signature")
+	ErrExpired = errors.New("token expired")
+)
+
+// Identity is what a token says about the player. Tokens are HS256 JWTs, so
+// any service holding the shared secret can issue them with a standard library.
+type Identity struct {
+	ID string `json:"sub"`
+	Name string `json:"name,omitempty"`
+	Expires int64 `json:"exp,omitempty"`		// unix seconds, 0 = never
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+type Verifier struct {
+	secret []byte
+}
+
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret)}
+}
+
+var header = encode([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign issues a token for id
+func (v *Verifier) Sign(id Identity) (string, error) {
+	claims, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + encode(claims)
+	return unsigned + "." + encode(v.mac(unsigned)), nil
+}
+
+// Verify checks the signature and validity window of token
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJSON(parts[0], &head); err != nil || head.Alg != "HS256" {
+		return nil, ErrMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(sig, v.mac(parts[0] + "." + parts[1])) {
+		return nil, ErrSignature
+	}
+
+	var id Identity
+	if err := decodeJSON(parts[1], &id); err != nil || id.ID == "" {
+		return nil, ErrMalformed
+	}
+
+	now := time.Now().Unix()
+	if id.Expires != 0 && now >= id.Expires {
+		return nil, ErrExpired
+	}
+	if id.NotBefore != 0 && now < id.NotBefore {
+		return nil, ErrExpired
+	}
+	return &id, nil
+}
+
+func (v *Verifier) mac(s string) []byte {
+	m := hmac.New(sha256.New, v.secret)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJSON(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func TestVerify(t *testing.T) {
+	v := NewVerifier(secret)
+	now := time.Now().Unix()
+
+	sign := func(id Identity) string { return signWith(t, v, id) }
+	good := sign(Identity{ID: "user-1", Name: "Ada", Expires: now + 60})
+	parts := strings.Split(good, ".")
+
+	tests := []struct {
+		name string
+		token string
+		err error
+	}{
+		{"valid", good, nil},
+		{"no expiry", sign(Identity{ID: "user-1"}), nil},
+		{"expired", sign(Identity{ID: "user-1", Expires: now - 1}), ErrExpired},
+		{"not yet valid", sign(Identity{ID: "user-1", NotBefore: now + 60}), ErrExpired},
+		{"no subject", sign(Identity{Name: "Ada"}), ErrMalformed},
+		{"other secret", signWith(t, NewVerifier(strings.Repeat("x", 32)), Identity{ID: "user-1"}), ErrSignature},
+		{"claims changed", parts[0] + "." + encode([]byte(`{"sub":"admin"}`)) + "." + parts[2], ErrSignature},
+		{"alg none", encode([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", ErrMalformed},
+		{"two parts", parts[0] + "." + parts[1], ErrMalformed},
+		{"bad signature encoding", parts[0] + "." + parts[1] + ".!!", ErrMalformed},
+		{"empty", "", ErrMalformed},
+	}
+	for _, tt := range tests {
+		id, err := v.Verify(tt.token)
+		if err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && id.ID != "user-1" {
+			t.Errorf("%s: got ID %q", tt.name, id.ID)
+		}
+	}
+
+	if id, _ := v.Verify(good); id.Name != "Ada" {
+		t.Errorf("name = %q, want Ada", id.Name)
+	}
+}
+
+func signWith(t *testing.T, v *Verifier, id Identity) string {
+	token, err := v.Sign(id)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,515 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxGridSize is the largest board the server will ever generate, whatever
+// the config says
+const MaxGridSize = 50
+
+// Config holds every server setting. Values are layered: built-in defaults,
+// then the JSON config file, then environment variables, then flags.
+type Config struct {
+	ListenAddr string `json:"listen_addr"`
+
+	// Named word lists, "default" is used unless a room picks another one
+	WordLists map[string]string `json:"word_lists"`
+
+	// Browser origins allowed to open sockets, empty allows any. Entries may
+	// use a leading wildcard host, e.g. "https://*.example.com"
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	Auth Auth `json:"auth"`
+	Admin Admin `json:"admin"`
+
+	Rooms Rooms `json:"rooms"`
+	Connection Connection `json:"connection"`
+	Limits Limits `json:"limits"`
+	AntiCheat AntiCheat `json:"anti_cheat"`
+	Validation Validation `json:"validation"`
+	Chat Chat `json:"chat"`
+	Logging Logging `json:"logging"`
+	Shutdown Shutdown `json:"shutdown"`
+}
+
+// Rooms holds defaults and limits for room settings
+type Rooms struct {
+	CodeLength int `json:"code_length"`
+
+	DefaultWordCount int `json:"default_word_count"`
+	MinWordCount int `json:"min_word_count"`
+	MaxWordCount int `json:"max_word_count"`
+
+	DefaultGridSize int `json:"default_grid_size"`
+	MinGridSize int `json:"min_grid_size"`
+	MaxGridSize int `json:"max_grid_size"`
+	MaxGridFill float64 `json:"max_grid_fill"`	// share of cells the words may take up
+
+	DefaultMaxPlayers int `json:"default_max_players"`
+	MaxPlayers int `json:"max_players"`
+
+	DefaultTimeLimit int `json:"default_time_limit"`	// seconds, co-op
+	MinTimeLimit int `json:"min_time_limit"`
+	MaxTimeLimit int `json:"max_time_limit"`
+
+	SeriesIntermission Duration `json:"series_intermission"`
+	RematchTimeout Duration `json:"rematch_timeout"`
+
+	// Idle rooms are closed after these, players are warned ExpiryWarning ahead
+	LobbyTimeout Duration `json:"lobby_timeout"`		// waiting for a first game
+	PostGameTimeout Duration `json:"post_game_timeout"`	// a game ended and nobody moved on
+	ExpiryWarning Duration `json:"expiry_warning"`
+
+	// A player whose connection drops during a game keeps their seat this
+	// long, connecting again with the same ID puts them back. 0 = no grace.
+	ReconnectGrace Duration `json:"reconnect_grace"`
+
+	// Wrong-guess penalties for rooms that turn them on
+	WrongGuessCooldown Duration `json:"wrong_guess_cooldown"`
+	WrongGuessPoints int `json:"wrong_guess_points"`
+
+	// selection_preview relays per player are at most this far apart, the
+	// latest one waits for its turn and older ones are dropped
+	PreviewInterval Duration `json:"preview_interval"`
+
+	// Hints each player may request per game and the points each one may cost
+	DefaultHints int `json:"default_hints"`
+	MaxHints int `json:"max_hints"`
+	MaxHintCost int `json:"max_hint_cost"`
+}
+
+// Auth configures token authentication on upgrade. Tokens are HS256 JWTs
+// signed with Secret, passed as ?token= or an "Authorization: Bearer" header.
+type Auth struct {
+	Secret string `json:"secret"`
+	Required bool `json:"required"`		// reject connections without a token
+}
+
+// Admin guards the /admin endpoints, which are disabled without a token
+type Admin struct {
+	Token string `json:"token"`		// sent as "Authorization: Bearer <token>"
+}
+
+type Connection struct {
+	SendQueueSize int `json:"send_queue_size"`
+	PingInterval Duration `json:"ping_interval"`
+	PongWait Duration `json:"pong_wait"`
+	WriteWait Duration `json:"write_wait"`
+	IdleTimeout Duration `json:"idle_timeout"`
+}
+
+// Limits protects the server from clients that flood it
+type Limits struct {
+	MaxMessageSize int64 `json:"max_message_size"`		// bytes, larger messages close the socket
+	MaxConnectionsPerIP int `json:"max_connections_per_ip"`
+	TrustProxy bool `json:"trust_proxy"`		// take the client IP from X-Forwarded-For
+	ProxyHops int `json:"proxy_hops"`		// trusted proxies in front of the server, each appends to X-Forwarded-For
+
+	// Token buckets per message type, "default" covers types not listed
+	Messages map[string]Rate `json:"messages"`
+	MaxViolations int `json:"max_violations"`		// rate limited messages before disconnecting
+
+	// Too many wrong join codes from one IP locks it out of joining for a while
+	MaxFailedJoins int `json:"max_failed_joins"`
+	FailedJoinWindow Duration `json:"failed_join_window"`
+	JoinLockout Duration `json:"join_lockout"`
+}
+
+type Logging struct {
+	Level string `json:"level"`		// debug, info, warn or error
+	Format string `json:"format"`	// json or text
+
+	// Below warning level, only the first Initial records with the same
+	// message per Interval are logged, then every Thereafter-th one.
+	// Initial 0 logs everything.
+	Sampling Sampling `json:"sampling"`
+}
+
+type Sampling struct {
+	Initial int `json:"initial"`
+	Thereafter int `json:"thereafter"`
+	Interval Duration `json:"interval"`
+}
+
+// Validation rules for player input
+type Validation struct {
+	NameMinLength int `json:"name_min_length"`
+	NameMaxLength int `json:"name_max_length"`
+	NamePattern string `json:"name_pattern"`		// regexp every name must match
+
+	// Names containing any of these are rejected, the file has one per line
+	BlockedWords []string `json:"blocked_words"`
+	BlockedWordsFile string `json:"blocked_words_file"`
+}
+
+// Chat between players of a room. Blocked words from Validation are masked.
+type Chat struct {
+	MaxLength int `json:"max_length"`		// characters per message
+	Emotes []string `json:"emotes"`			// names clients may send as emote
+	HistoryLines int `json:"history_lines"`	// lines kept per match for review
+}
+
+// AntiCheat watches word selections for bots. Misses beyond MaxMisses within
+// MissWindow get a cooldown and optionally cost points, suspicious patterns
+// are flagged in the match history for operators to review.
+type AntiCheat struct {
+	MaxMisses int `json:"max_misses"`
+	MissWindow Duration `json:"miss_window"`
+	Cooldown Duration `json:"cooldown"`		// select_word is rejected meanwhile
+	PointPenalty int `json:"point_penalty"`	// deducted with every cooldown, 0 = none
+
+	MinClaimInterval Duration `json:"min_claim_interval"`	// faster claims are flagged
+	FlagMisses int `json:"flag_misses"`		// misses in one game before flagging
+	SweepLength int `json:"sweep_length"`		// misses in a row from neighbouring cells
+
+	MatchHistory int `json:"match_history"`		// finished matches kept in memory
+}
+
+type Rate struct {
+	PerSecond float64 `json:"per_second"`
+	Burst int `json:"burst"`
+}
+
+type Shutdown struct {
+	Timeout Duration `json:"timeout"`
+	WaitForGames bool `json:"wait_for_games"`
+}
+
+// Duration reads "30s" style strings from JSON
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func Default() *Config {
+	return &Config{
+		ListenAddr: ":8080",
+		WordLists: map[string]string{
+			"default": "config/words.txt",
+		},
+		Rooms: Rooms{
+			CodeLength: 6,
+			DefaultWordCount: 7,
+			MinWordCount: 1,
+			MaxWordCount: 30,
+			DefaultGridSize: 12,
+			MinGridSize: 11,
+			MaxGridSize: 30,
+			MaxGridFill: 0.5,
+			DefaultMaxPlayers: 2,
+			MaxPlayers: 8,
+			DefaultTimeLimit: 180,
+			MinTimeLimit: 30,
+			MaxTimeLimit: 3600,
+			SeriesIntermission: Duration{5 * time.Second},
+			RematchTimeout: Duration{30 * time.Second},
+			LobbyTimeout: Duration{15 * time.Minute},
+			PostGameTimeout: Duration{5 * time.Minute},
+			ExpiryWarning: Duration{time.Minute},
+			ReconnectGrace: Duration{30 * time.Second},
+			WrongGuessCooldown: Duration{3 * time.Second},
+			WrongGuessPoints: 1,
+			PreviewInterval: Duration{50 * time.Millisecond},
+			DefaultHints: 3,
+			MaxHints: 10,
+			MaxHintCost: 5,
+		},
+		Connection: Connection{
+			SendQueueSize: 16,
+			PingInterval: Duration{25 * time.Second},
+			PongWait: Duration{60 * time.Second},
+			WriteWait: Duration{10 * time.Second},
+			IdleTimeout: Duration{10 * time.Minute},
+		},
+		Limits: Limits{
+			MaxMessageSize: 4096,
+			MaxConnectionsPerIP: 10,
+			ProxyHops: 1,
+			Messages: map[string]Rate{
+				"default": {PerSecond: 10, Burst: 20},
+				"select_word": {PerSecond: 3, Burst: 6},
+				"create_room": {PerSecond: 0.5, Burst: 3},
+				"join_room": {PerSecond: 0.5, Burst: 3},
+				"name_change": {PerSecond: 0.5, Burst: 3},
+				"chat": {PerSecond: 1, Burst: 5},
+				"emote": {PerSecond: 1, Burst: 3},
+				"selection_preview": {PerSecond: 30, Burst: 30},
+			},
+			MaxViolations: 50,
+			MaxFailedJoins: 5,
+			FailedJoinWindow: Duration{time.Minute},
+			JoinLockout: Duration{5 * time.Minute},
+		},
+		Logging: Logging{
+			Level: "info",
+			Format: "json",
+			Sampling: Sampling{
+				Initial: 100,
+				Thereafter: 100,
+				Interval: Duration{time.Second},
+			},
+		},
+		Validation: Validation{
+			NameMinLength: 1,
+			NameMaxLength: 20,
+			NamePattern: `^[\p{L}\p{N} _.'-]+$`,
+		},
+		Chat: Chat{
+			MaxLength: 200,
+			Emotes: []string{"gg", "wp", "nice", "oops", "thinking", "hurry"},
+			HistoryLines: 200,
+		},
+		AntiCheat: AntiCheat{
+			MaxMisses: 8,
+			MissWindow: Duration{10 * time.Second},
+			Cooldown: Duration{5 * time.Second},
+			MinClaimInterval: Duration{300 * time.Millisecond},
+			FlagMisses: 30,
+			SweepLength: 8,
+			MatchHistory: 200,
+		},
+		Shutdown: Shutdown{
+			Timeout: Duration{30 * time.Second},
+		},
+	}
+}
+
+// Load builds the config from defaults, the file given by -config (or
+// WORDSEARCH_CONFIG), environment variables and command line flags
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("WORDSEARCH_CONFIG"), "path to a JSON config file")
+	addr := fs.String("addr", "", "listen address, e.g. :8080")
+	wordlist := fs.String("wordlist", "", "path of the default word list")
+	origins := fs.String("allowed-origins", "", "comma separated list of allowed origins")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if *addr != "" {
+		cfg.ListenAddr = *addr
+	}
+	if *wordlist != "" {
+		cfg.WordLists["default"] = *wordlist
+	}
+	if *origins != "" {
+		cfg.AllowedOrigins = splitList(*origins)
+	}
+	if *logLevel != "" {
+		cfg.Logging.Level = *logLevel
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("PORT"); v != "" {
+		c.ListenAddr = ":" + v
+	}
+	if v := os.Getenv("WORDSEARCH_ADDR"); v != "" {
+		c.ListenAddr = v
+	}
+	if v := os.Getenv("WORDSEARCH_WORDLIST"); v != "" {
+		c.WordLists["default"] = v
+	}
+	if v := os.Getenv("WORDSEARCH_ALLOWED_ORIGINS"); v != "" {
+		c.AllowedOrigins = splitList(v)
+	}
+	if v := os.Getenv("WORDSEARCH_AUTH_SECRET"); v != "" {
+		c.Auth.Secret = v
+	}
+	if v := os.Getenv("WORDSEARCH_ADMIN_TOKEN"); v != "" {
+		c.Admin.Token = v
+	}
+	if v := os.Getenv("WORDSEARCH_AUTH_REQUIRED"); v != "" {
+		c.Auth.Required = v == "true"
+	}
+	if v := os.Getenv("WORDSEARCH_LOG_LEVEL"); v != "" {
+		c.Logging.Level = v
+	}
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SHUTDOWN_TIMEOUT: %v", err)
+		}
+		c.Shutdown.Timeout.Duration = d
+	}
+	if v := os.Getenv("SHUTDOWN_WAIT_FOR_GAMES"); v != "" {
+		c.Shutdown.WaitForGames = v == "true"
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Validate checks that the settings make sense together
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	r := c.Rooms
+	check(c.ListenAddr != "", "listen_addr is required")
+	check(c.WordLists["default"] != "", "word_lists needs a \"default\" entry")
+	check(r.CodeLength >= 4 && r.CodeLength <= 12, "rooms.code_length must be between 4 and 12")
+
+	check(r.MinWordCount >= 1, "rooms.min_word_count must be at least 1")
+	check(r.MinWordCount <= r.MaxWordCount, "rooms.min_word_count is above rooms.max_word_count")
+	check(r.DefaultWordCount >= r.MinWordCount && r.DefaultWordCount <= r.MaxWordCount,
+		"rooms.default_word_count must be within the word count limits")
+
+	check(r.MinGridSize >= 5, "rooms.min_grid_size must be at least 5")
+	check(r.MinGridSize <= r.MaxGridSize, "rooms.min_grid_size is above rooms.max_grid_size")
+	check(r.DefaultGridSize >= r.MinGridSize && r.DefaultGridSize <= r.MaxGridSize,
+		"rooms.default_grid_size must be within the grid size limits")
+	check(r.MaxGridSize <= MaxGridSize, "rooms.max_grid_size can't be above %d", MaxGridSize)
+	check(r.MaxGridFill > 0 && r.MaxGridFill <= 1, "rooms.max_grid_fill must be above 0 and at most 1")
+
+	check(r.MaxPlayers >= 2 && r.MaxPlayers <= 8, "rooms.max_players must be between 2 and 8")
+	check(r.DefaultMaxPlayers >= 2 && r.DefaultMaxPlayers <= r.MaxPlayers,
+		"rooms.default_max_players must be between 2 and rooms.max_players")
+
+	check(r.MinTimeLimit > 0 && r.MinTimeLimit <= r.MaxTimeLimit, "rooms time limits are invalid")
+	check(r.DefaultTimeLimit >= r.MinTimeLimit && r.DefaultTimeLimit <= r.MaxTimeLimit,
+		"rooms.default_time_limit must be within the time limits")
+
+	check(r.SeriesIntermission.Duration >= 0, "rooms.series_intermission can't be negative")
+	check(r.RematchTimeout.Duration > 0, "rooms.rematch_timeout must be positive")
+	check(r.ExpiryWarning.Duration > 0, "rooms.expiry_warning must be positive")
+	check(r.ReconnectGrace.Duration >= 0, "rooms.reconnect_grace can't be negative")
+	check(r.LobbyTimeout.Duration > r.ExpiryWarning.Duration && r.PostGameTimeout.Duration > r.ExpiryWarning.Duration,
+		"rooms.lobby_timeout and rooms.post_game_timeout must be longer than rooms.expiry_warning")
+	check(r.WrongGuessCooldown.Duration > 0, "rooms.wrong_guess_cooldown must be positive")
+	check(r.WrongGuessPoints > 0, "rooms.wrong_guess_points must be positive")
+	check(r.PreviewInterval.Duration > 0, "rooms.preview_interval must be positive")
+	check(r.DefaultHints >= 0 && r.DefaultHints <= r.MaxHints, "rooms.default_hints must be between 0 and rooms.max_hints")
+	check(r.MaxHintCost >= 0, "rooms.max_hint_cost can't be negative")
+
+	conn := c.Connection
+	check(conn.SendQueueSize > 0, "connection.send_queue_size must be positive")
+	check(conn.PingInterval.Duration > 0, "connection.ping_interval must be positive")
+	check(conn.PongWait.Duration > conn.PingInterval.Duration, "connection.pong_wait must be longer than connection.ping_interval")
+	check(conn.WriteWait.Duration > 0, "connection.write_wait must be positive")
+	check(conn.IdleTimeout.Duration > 0, "connection.idle_timeout must be positive")
+
+	limits := c.Limits
+	check(limits.MaxMessageSize >= 512, "limits.max_message_size must be at least 512")
+	check(limits.MaxConnectionsPerIP > 0, "limits.max_connections_per_ip must be positive")
+	check(!limits.TrustProxy || limits.ProxyHops > 0, "limits.proxy_hops must be positive with trust_proxy")
+	check(limits.MaxViolations > 0, "limits.max_violations must be positive")
+	_, ok := limits.Messages["default"]
+	check(ok, "limits.messages needs a \"default\" entry")
+	for name, rate := range limits.Messages {
+		check(rate.PerSecond > 0 && rate.Burst >= 1, "limits.messages.%s needs a positive per_second and burst", name)
+	}
+	check(limits.MaxFailedJoins > 0, "limits.max_failed_joins must be positive")
+	check(limits.FailedJoinWindow.Duration > 0 && limits.JoinLockout.Duration > 0,
+		"limits.failed_join_window and limits.join_lockout must be positive")
+
+	logging := c.Logging
+	var level slog.Level
+	check(level.UnmarshalText([]byte(logging.Level)) == nil, "logging.level must be debug, info, warn or error")
+	check(logging.Format == "json" || logging.Format == "text", "logging.format must be json or text")
+	check(logging.Sampling.Initial >= 0 && logging.Sampling.Thereafter >= 0, "logging.sampling can't be negative")
+	check(logging.Sampling.Initial == 0 || logging.Sampling.Interval.Duration > 0, "logging.sampling.interval must be positive")
+
+	val := c.Validation
+	check(val.NameMinLength >= 1 && val.NameMinLength <= val.NameMaxLength, "validation name lengths are invalid")
+	_, err := regexp.Compile(val.NamePattern)
+	check(err == nil, "validation.name_pattern: %v", err)
+
+	check(c.Chat.MaxLength > 0, "chat.max_length must be positive")
+	check(c.Chat.HistoryLines >= 0, "chat.history_lines can't be negative")
+	for _, emote := range c.Chat.Emotes {
+		check(emote != "", "chat.emotes can't contain an empty name")
+	}
+
+	ac := c.AntiCheat
+	check(ac.MaxMisses > 0 && ac.MissWindow.Duration > 0, "anti_cheat.max_misses and anti_cheat.miss_window must be positive")
+	check(ac.Cooldown.Duration >= 0 && ac.PointPenalty >= 0, "anti_cheat penalties can't be negative")
+	check(ac.MinClaimInterval.Duration >= 0, "anti_cheat.min_claim_interval can't be negative")
+	check(ac.FlagMisses > 0 && ac.SweepLength > 1, "anti_cheat.flag_misses must be positive and anti_cheat.sweep_length above 1")
+	check(ac.MatchHistory >= 0, "anti_cheat.match_history can't be negative")
+
+	check(c.Shutdown.Timeout.Duration > 0, "shutdown.timeout must be positive")
+
+	check(!c.Auth.Required || c.Auth.Secret != "", "auth.required needs auth.secret")
+	check(c.Auth.Secret == "" || len(c.Auth.Secret) >= 32, "auth.secret must be at least 32 characters")
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 32, "admin.token must be at least 32 characters")
+	for _, origin := range c.AllowedOrigins {
+		check(strings.Contains(origin, "://"), "allowed origin %q needs a scheme, e.g. https://example.com", origin)
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
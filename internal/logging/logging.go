@@ -0,0 +1,101 @@
+// Package logging builds the server's structured logger
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+// New returns a logger writing cfg.Format records of cfg.Level and above to w
+func New(cfg config.Logging, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("logging.level: %v", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.Format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging.format must be json or text")
+	}
+
+	if s := cfg.Sampling; s.Initial > 0 {
+		h = &sampler{
+			next: h,
+			initial: s.Initial,
+			thereafter: s.Thereafter,
+			interval: s.Interval.Duration,
+			state: &sampleState{counts: make(map[string]int)},
+		}
+	}
+	return slog.New(h), nil
+}
+
+// sampler lets through the first initial records with the same level and
+// message per interval, then every thereafter-th one. Warnings and errors
+// are never dropped.
+type sampler struct {
+	next slog.Handler
+	initial, thereafter int
+	interval time.Duration
+	state *sampleState	// shared by every logger derived from this one
+}
+
+type sampleState struct {
+	mu sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *sampler) Handle(ctx context.Context, rec slog.Record) error {
+	if rec.Level >= slog.LevelWarn || s.keep(rec) {
+		return s.next.Handle(ctx, rec)
+	}
+	return nil
+}
+
+func (s *sampler) keep(rec slog.Record) bool {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if rec.Time.Sub(st.window) >= s.interval {
+		st.window = rec.Time
+		st.counts = make(map[string]int)
+	}
+
+	key := rec.Level.String() + " " + rec.Message
+	n := st.counts[key]
+	st.counts[key] = n + 1
+
+	if n < s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial+1)%s.thereafter == 0
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *s
+	c.next = s.next.WithAttrs(attrs)
+	return &c
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	c := *s
+	c.next = s.next.WithGroup(name)
+	return &c
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled at Rate tokens per second up to Burst.
+// It isn't safe for concurrent use, every connection owns its buckets.
+type Bucket struct {
+	rate float64
+	burst float64
+	tokens float64
+	last time.Time
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate: rate,
+		burst: float64(burst),
+		tokens: float64(burst),
+		last: time.Now(),
+	}
+}
+
+// Allow takes a token if one is available
+func (b *Bucket) Allow() bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Counter caps how many things (connections) a key (IP) holds at once
+type Counter struct {
+	mu sync.Mutex
+	limit int
+	counts map[string]int
+}
+
+func NewCounter(limit int) *Counter {
+	return &Counter{
+		limit: limit,
+		counts: make(map[string]int),
+	}
+}
+
+// Acquire takes a slot for key, false when key is at the limit
+func (c *Counter) Acquire(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] >= c.limit {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
+
+func (c *Counter) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+	} else {
+		c.counts[key]--
+	}
+}
+
+// Lockout locks a key out for a while after too many failures within a window
+type Lockout struct {
+	mu sync.Mutex
+	maxFailures int
+	window time.Duration
+	duration time.Duration
+	entries map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	failures int
+	first time.Time
+	lockedUntil time.Time
+}
+
+func NewLockout(maxFailures int, window, duration time.Duration) *Lockout {
+	return &Lockout{
+		maxFailures: maxFailures,
+		window: window,
+		duration: duration,
+		entries: make(map[string]*lockoutEntry),
+	}
+}
+
+// LockedFor returns how long key is still locked out, 0 if it isn't
+func (l *Lockout) LockedFor(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok {
+		if left := time.Until(e.lockedUntil); left > 0 {
+			return left
+		}
+	}
+	return 0
+}
+
+// Fail records a failure and reports whether key is now locked out
+func (l *Lockout) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.entries) > 1024 {
+		l.prune(now)
+	}
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.first) > l.window {
+		e = &lockoutEntry{first: now}
+		l.entries[key] = e
+	}
+
+	e.failures++
+	if e.failures >= l.maxFailures {
+		e.lockedUntil = now.Add(l.duration)
+		e.failures = 0
+		e.first = now
+		return true
+	}
+	return false
+}
+
+// prune expects l.mu to be held
+func (l *Lockout) prune(now time.Time) {
+	for key, e := range l.entries {
+		if now.Sub(e.first) > l.window && now.After(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
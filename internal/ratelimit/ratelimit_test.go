@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	b := NewBucket(10, 3)
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("message %d of the burst was limited", i+1)
+		}
+	}
+	if b.Allow() {
+		t.Fatal("burst exceeded")
+	}
+
+	// 10 per second is one token every 100ms
+	b.last = b.last.Add(-150 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("no token after refilling")
+	}
+	if b.Allow() {
+		t.Fatal("refilled more than one token")
+	}
+
+	// never more than the burst, however long it was idle
+	b.last = b.last.Add(-time.Hour)
+	n := 0
+	for b.Allow() {
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("allowed %d after idling, want 3", n)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter(2)
+	if !c.Acquire("a") || !c.Acquire("a") {
+		t.Fatal("limit hit early")
+	}
+	if c.Acquire("a") {
+		t.Fatal("acquired past the limit")
+	}
+	if !c.Acquire("b") {
+		t.Fatal("keys share a limit")
+	}
+
+	c.Release("a")
+	if !c.Acquire("a") {
+		t.Fatal("release didn't free a slot")
+	}
+	c.Release("a")
+	c.Release("a")
+	if _, ok := c.counts["a"]; ok {
+		t.Fatal("released key still counted")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l := NewLockout(3, time.Minute, time.Minute)
+
+	if l.Fail("ip") || l.Fail("ip") {
+		t.Fatal("locked out before max failures")
+	}
+	if l.LockedFor("ip") != 0 {
+		t.Fatal("locked out before max failures")
+	}
+	if !l.Fail("ip") {
+		t.Fatal("not locked out after max failures")
+	}
+	if left := l.LockedFor("ip"); left <= 0 || left > time.Minute {
+		t.Fatalf("locked for %v", left)
+	}
+	if l.LockedFor("other") != 0 {
+		t.Fatal("lockout leaked to another key")
+	}
+
+	// the lockout runs out
+	l.entries["ip"].lockedUntil = time.Now().Add(-time.Second)
+	if l.LockedFor("ip") != 0 {
+		t.Fatal("still locked out after the lockout")
+	}
+}
+
+func TestLockoutWindow(t *testing.T) {
+	l := NewLockout(2, time.Minute, time.Minute)
+
+	// failures further apart than the window don't add up
+	l.Fail("ip")
+	l.entries["ip"].first = time.Now().Add(-2 * time.Minute)
+	if l.Fail("ip") {
+		t.Fatal("locked out by failures outside the window")
+	}
+	if !l.Fail("ip") {
+		t.Fatal("not locked out by failures within the window")
+	}
+}
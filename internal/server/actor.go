@@ -0,0 +1,41 @@
+package server
+
+// Every room runs its own goroutine that executes commands one at a time.
+// Handlers, timers and the server all go through Do, so room and game state
+// never need a lock.
+
+func (r *Room) run() {
+	for {
+		select {
+		case cmd := <-r.cmds:
+			cmd()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Do runs fn on the room goroutine and waits for it to finish. It returns
+// false without running fn if the room has been closed. fn must not call Do
+// on the same room.
+func (r *Room) Do(fn func()) bool {
+	finished := make(chan struct{})
+	cmd := func() {
+		defer close(finished)
+		fn()
+	}
+
+	select {
+	case r.cmds <- cmd:
+	case <-r.done:
+		return false
+	}
+
+	<-finished
+	return true
+}
+
+// Close stops the room goroutine, pending and future Do calls return false
+func (r *Room) Close() {
+	close(r.done)
+}
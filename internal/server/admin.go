@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrPlayerNotFound = errors.New("player not found")
+)
+
+// RoomInfo is an operator's view of a room
+type RoomInfo struct {
+	ID string `json:"id"`
+	JoinCode string `json:"join_code"`
+	HostID string `json:"host_id"`
+	Locked bool `json:"locked"`
+	Players []PlayerInfo `json:"players"`
+	Settings RoomSettings `json:"settings"`
+
+	GameStarted bool `json:"game_started"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	Words []string `json:"words,omitempty"`
+	Claimed int `json:"claimed"`
+	TeamScore map[int]int `json:"team_score,omitempty"`
+
+	Round int `json:"round"`
+	InIntermission bool `json:"in_intermission"`
+}
+
+type PlayerInfo struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	Number int `json:"number"`
+	Team int `json:"team,omitempty"`
+	Ready bool `json:"ready"`
+	Score int `json:"score"`
+	SeriesScore int `json:"series_score"`
+}
+
+func (r *Room) info() RoomInfo {
+	g := r.GameState
+	info := RoomInfo{
+		ID: r.ID,
+		JoinCode: r.JoinCode,
+		HostID: r.HostID,
+		Locked: r.Locked,
+		Settings: r.Settings,
+		GameStarted: g.GameStarted,
+		Round: r.Round,
+		InIntermission: r.InIntermission(),
+	}
+	if g.GameStarted {
+		info.StartedAt = g.StartedAt
+		info.Words = append([]string(nil), g.Words...)
+		info.Claimed = len(g.Claimed)
+		if g.Mode == ModeTeams {
+			info.TeamScore = g.teamScore()
+		}
+	}
+
+	for _, p := range r.Players {
+		info.Players = append(info.Players, PlayerInfo{
+			ID: p.ID,
+			Name: p.Name,
+			Number: p.Number,
+			Team: p.Team,
+			Ready: r.Ready[p.ID],
+			Score: g.Score[p.ID],
+			SeriesScore: r.SeriesScore[p.ID],
+		})
+	}
+	return info
+}
+
+// Rooms describes every open room
+func (s *Server) Rooms() []RoomInfo {
+	s.mu.Lock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.Unlock()
+
+	infos := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		var info RoomInfo
+		if room.Do(func() { info = room.info() }) {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// CloseRoom ends whatever the room is doing and sends its players back to
+// the lobby with a room_closed message. Their connections stay open.
+func (s *Server) CloseRoom(id, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[id]
+	if !ok {
+		return ErrRoomNotFound
+	}
+
+	room.Do(func() { room.closeAll(reason) })
+	s.removeRoom(room)
+
+	room.logger().Info("room closed", "reason", reason)
+	return nil
+}
+
+// KickPlayer takes a player out of their room and closes the connection.
+// Leaving first means the seat isn't held for a reconnect.
+func (s *Server) KickPlayer(id, reason string) error {
+	s.mu.Lock()
+	p, ok := s.players[id]
+	if ok {
+		s.leaveRoom(p)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrPlayerNotFound
+	}
+
+	p.Logger().Info("kicking player", "reason", reason)
+	p.Close(websocket.ClosePolicyViolation, reason)
+	return nil
+}
+
+// Ready reports why the server can't take players, nil when it can
+func (s *Server) Ready() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return errors.New("shutting down")
+	}
+	if len(s.wordlists["default"]) == 0 {
+		return errors.New("default word list not loaded")
+	}
+	return nil
+}
+
+// closeAll stops every timer, records an unfinished game as abandoned and
+// unseats everyone
+func (r *Room) closeAll(reason string) {
+	if r.GameState.GameStarted {
+		r.finishMatch(true)
+	}
+	r.GameState.GameStarted = false
+	r.stopClock()
+	r.resetSeries()
+	r.clearRematch()
+
+	r.Broadcast(map[string]interface{}{
+		"type": "room_closed",
+		"payload": map[string]interface{}{
+			"reason": reason,
+		},
+	})
+	for _, p := range r.Players {
+		p.SetRoom(nil)
+	}
+	r.Players = nil
+	r.Ready = make(map[string]bool)
+}
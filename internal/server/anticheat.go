@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// selectionStats is what the room knows about one player's selections in
+// the running game
+type selectionStats struct {
+	lastClaim time.Time
+	misses []time.Time		// within the miss window
+	totalMisses int
+	sweep int 				// misses in a row starting next to the previous one
+	lastMiss Coord
+	cooldownUntil time.Time
+	flagged map[string]bool	// kinds already flagged this game
+}
+
+// SelectWord claims a selection for p, watches for bot-like patterns and
+// applies the room's wrong-guess penalty. Players serving a cooldown can't
+// select at all.
+func (r *Room) SelectWord(p *Player, start, end Coord) (interface{}, error) {
+	if !r.GameState.GameStarted {
+		return nil, errors.New("game not started")
+	}
+	if _, playing := r.GameState.Score[p.ID]; !playing {
+		return nil, errors.New("not playing in this game")
+	}
+
+	stats := r.statsFor(p)
+	if left := time.Until(stats.cooldownUntil); left > 0 {
+		return nil, fmt.Errorf("selections blocked for %d more seconds", int(left.Seconds())+1)
+	}
+
+	msg, err := r.GameState.ClaimWord(p, start, end, r)
+	switch err {
+	case nil:
+		r.recordClaim(p, stats)
+	case errWordClaimed: // lost a race, not a miss
+	default:
+		r.recordMiss(p, stats, start)
+		r.wrongGuess(p, stats)
+	}
+	return msg, err
+}
+
+func (r *Room) statsFor(p *Player) *selectionStats {
+	stats, ok := r.selections[p.ID]
+	if !ok {
+		stats = &selectionStats{
+			lastClaim: r.GameState.StartedAt,
+			flagged: make(map[string]bool),
+		}
+		r.selections[p.ID] = stats
+	}
+	return stats
+}
+
+func (r *Room) recordClaim(p *Player, stats *selectionStats) {
+	now := time.Now()
+	if gap := now.Sub(stats.lastClaim); gap < r.antiCheat.MinClaimInterval.Duration {
+		r.flag(p, stats, "fast_claim", fmt.Sprintf("claimed a word %v after the previous one", gap.Round(time.Millisecond)))
+	}
+	stats.lastClaim = now
+	stats.sweep = 0
+}
+
+func (r *Room) recordMiss(p *Player, stats *selectionStats, start Coord) {
+	now := time.Now()
+	cfg := r.antiCheat
+
+	invalidSelections.Inc()
+	stats.totalMisses++
+	if stats.totalMisses >= cfg.FlagMisses {
+		r.flag(p, stats, "brute_force", fmt.Sprintf("%d invalid selections", stats.totalMisses))
+	}
+
+	// scanning the grid cell by cell, or every direction from one cell
+	if stats.totalMisses > 1 && abs(start.Row-stats.lastMiss.Row) <= 1 && abs(start.Col-stats.lastMiss.Col) <= 1 {
+		stats.sweep++
+	} else {
+		stats.sweep = 1
+	}
+	stats.lastMiss = start
+	if stats.sweep >= cfg.SweepLength {
+		r.flag(p, stats, "sweep", fmt.Sprintf("%d invalid selections from neighbouring cells", stats.sweep))
+	}
+
+	recent := stats.misses[:0]
+	for _, t := range stats.misses {
+		if now.Sub(t) < cfg.MissWindow.Duration {
+			recent = append(recent, t)
+		}
+	}
+	stats.misses = append(recent, now)
+
+	if len(stats.misses) >= cfg.MaxMisses {
+		stats.misses = stats.misses[:0]
+		r.penalize(p, stats, cfg.Cooldown.Duration, cfg.PointPenalty, "too_many_misses")
+	}
+}
+
+// penalize puts p on cooldown and deducts points, scores never go below zero.
+// Everyone in the room is told so clients can show it.
+func (r *Room) penalize(p *Player, stats *selectionStats, cooldown time.Duration, points int, reason string) {
+	g := r.GameState
+	if until := time.Now().Add(cooldown); until.After(stats.cooldownUntil) {
+		stats.cooldownUntil = until
+	}
+
+	points = g.deduct(p, points)
+
+	payload := map[string]interface{}{
+		"player_number": p.Number,
+		"reason": reason,
+		"cooldown_ms": int(cooldown / time.Millisecond),
+		"points": points,
+		"score": r.byNumber(g.Score),
+	}
+	if g.Mode == ModeTeams {
+		payload["team_score"] = g.teamScore()
+	}
+	r.Broadcast(map[string]interface{}{
+		"type": "penalty",
+		"payload": payload,
+	})
+}
+
+// flag records a suspicious pattern once per kind and player per game
+func (r *Room) flag(p *Player, stats *selectionStats, kind, detail string) {
+	if stats.flagged[kind] {
+		return
+	}
+	stats.flagged[kind] = true
+
+	r.logger().Warn("anti-cheat flag", "player_id", p.ID, "kind", kind, "detail", detail)
+	if r.match != nil {
+		r.history.flag(r.match, Flag{PlayerID: p.ID, Kind: kind, Detail: detail, At: time.Now()})
+	}
+}
+
+// startMatch opens a match record for the game that just started
+func (r *Room) startMatch() {
+	r.selections = make(map[string]*selectionStats)
+	r.match = r.history.start(r)
+	r.chatLog = r.match
+	gamesStarted.Inc(r.GameState.Mode)
+	gamesInProgress.Inc()
+}
+
+// finishMatch closes the record of the running game, if any
+func (r *Room) finishMatch(abandoned bool) {
+	if r.match == nil {
+		return
+	}
+	r.history.finish(r.match, r.GameState, abandoned)
+	r.match = nil
+
+	gamesInProgress.Dec()
+	gamesFinished.Inc(r.GameState.endReason(abandoned))
+}
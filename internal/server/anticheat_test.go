@@ -0,0 +1,118 @@
+package server
+
+import (
+	"testing"
+	"time"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+// cheatRoom is testGame with small anti-cheat limits
+func cheatRoom(players ...*Player) (*Room, *GameState) {
+	r, g := testGame(ModeLockout, 5, players...)
+	r.selections = make(map[string]*selectionStats)
+	r.antiCheat = config.AntiCheat{
+		MaxMisses: 4,
+		MissWindow: config.Duration{Duration: 10 * time.Second},
+		Cooldown: config.Duration{Duration: 5 * time.Second},
+		PointPenalty: 1,
+		MinClaimInterval: config.Duration{Duration: 300 * time.Millisecond},
+		FlagMisses: 6,
+		SweepLength: 3,
+	}
+	return r, g
+}
+
+func TestRecordMiss(t *testing.T) {
+	scattered := []Coord{{0, 0}, {5, 5}, {0, 9}, {9, 0}, {9, 9}, {3, 3}}
+	tests := []struct {
+		name string
+		misses []Coord
+		flags []string
+		cooldown bool
+	}{
+		{"a few misses", scattered[:3], nil, false},
+		{"sweep", []Coord{{0, 0}, {0, 1}, {1, 2}}, []string{"sweep"}, false},
+		{"sweep broken off", []Coord{{0, 0}, {0, 1}, {5, 5}, {5, 6}}, nil, true},
+		{"too many misses", scattered[:4], nil, true},
+		{"brute force", scattered, []string{"brute_force"}, true},
+	}
+	for _, tt := range tests {
+		p := NewPlayer("p1", nil, 16)
+		r, g := cheatRoom(p)
+		g.Score[p.ID] = 3
+		stats := r.statsFor(p)
+		for _, c := range tt.misses {
+			r.recordMiss(p, stats, c)
+		}
+
+		if len(stats.flagged) != len(tt.flags) {
+			t.Errorf("%s: flagged %v, want %v", tt.name, stats.flagged, tt.flags)
+		}
+		for _, kind := range tt.flags {
+			if !stats.flagged[kind] {
+				t.Errorf("%s: not flagged %s", tt.name, kind)
+			}
+		}
+		if cooling := time.Now().Before(stats.cooldownUntil); cooling != tt.cooldown {
+			t.Errorf("%s: cooldown = %v", tt.name, cooling)
+		}
+		if tt.cooldown && g.Score[p.ID] != 2 {
+			t.Errorf("%s: score %d after the penalty, want 2", tt.name, g.Score[p.ID])
+		}
+	}
+}
+
+func TestRecordClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		gap time.Duration	// since the previous claim
+		flagged bool
+	}{
+		{"human speed", time.Second, false},
+		{"right after the last one", 50 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		p := NewPlayer("p1", nil, 16)
+		r, _ := cheatRoom(p)
+		stats := r.statsFor(p)
+		stats.lastClaim = time.Now().Add(-tt.gap)
+		stats.sweep = 2
+
+		r.recordClaim(p, stats)
+		if stats.flagged["fast_claim"] != tt.flagged {
+			t.Errorf("%s: fast_claim = %v", tt.name, stats.flagged["fast_claim"])
+		}
+		if stats.sweep != 0 {
+			t.Errorf("%s: a claim didn't end the sweep", tt.name)
+		}
+	}
+}
+
+func TestPenalize(t *testing.T) {
+	p := NewPlayer("p1", nil, 16)
+	r, g := cheatRoom(p)
+	g.Score[p.ID] = 1
+	stats := r.statsFor(p)
+
+	r.penalize(p, stats, 5*time.Second, 3, "test")
+	until := stats.cooldownUntil
+	if g.Score[p.ID] != 0 {
+		t.Errorf("score = %d, want 0", g.Score[p.ID])
+	}
+	if left := time.Until(until); left < 4*time.Second || left > 5*time.Second {
+		t.Errorf("cooldown %v, want 5s", left)
+	}
+	if payload := received(p, "penalty"); payload == nil || payload["points"] != 1 {
+		t.Errorf("penalty = %v, want 1 point taken", payload)
+	}
+
+	// a shorter cooldown never cuts a running one short
+	r.penalize(p, stats, time.Second, 0, "test")
+	if !stats.cooldownUntil.Equal(until) {
+		t.Errorf("cooldown shortened")
+	}
+
+	if _, err := r.SelectWord(p, Coord{0, 0}, Coord{0, 1}); err == nil {
+		t.Errorf("selected during a cooldown")
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+// ChatLine is a chat message or emote kept in the match history
+type ChatLine struct {
+	PlayerID string `json:"player_id"`
+	Name string `json:"name"`
+	Text string `json:"text,omitempty"`
+	Emote string `json:"emote,omitempty"`
+	At time.Time `json:"at"`
+}
+
+// Chat sends text to everyone in the room who hasn't muted p. The handler
+// has already validated and filtered it.
+func (r *Room) Chat(p *Player, text string) {
+	r.relay(p, map[string]interface{}{
+		"type": "chat",
+		"payload": map[string]interface{}{
+			"player_number": p.Number,
+			"name": p.Name,
+			"text": text,
+		},
+	}, ChatLine{PlayerID: p.ID, Name: p.Name, Text: text, At: time.Now()})
+}
+
+// Emote is a chat message from the configured list of emotes
+func (r *Room) Emote(p *Player, emote string) {
+	r.relay(p, map[string]interface{}{
+		"type": "emote",
+		"payload": map[string]interface{}{
+			"player_number": p.Number,
+			"emote": emote,
+		},
+	}, ChatLine{PlayerID: p.ID, Name: p.Name, Emote: emote, At: time.Now()})
+}
+
+func (r *Room) relay(from *Player, msg interface{}, line ChatLine) {
+	for _, p := range r.Players {
+		if !r.muted[p.ID][from.ID] {
+			p.Send(msg)
+		}
+	}
+
+	// lines after a game go to its record too, abuse tends to follow a loss
+	if r.chatLog != nil {
+		r.history.chat(r.chatLog, line, r.chatLines)
+	}
+}
+
+// Mute hides or shows chat and emotes from the player in seat number to p only
+func (r *Room) Mute(p *Player, number int, muted bool) error {
+	target := r.playerByNumber(number)
+	if target == nil {
+		return errors.New("no such player")
+	}
+	if target == p {
+		return errors.New("can't mute yourself")
+	}
+
+	if muted {
+		if r.muted[p.ID] == nil {
+			r.muted[p.ID] = make(map[string]bool)
+		}
+		r.muted[p.ID][target.ID] = true
+	} else {
+		delete(r.muted[p.ID], target.ID)
+	}
+
+	p.Send(map[string]interface{}{
+		"type": "mute_update",
+		"payload": map[string]interface{}{
+			"player_number": number,
+			"muted": muted,
+		},
+	})
+	return nil
+}
+
+// mutedBy lists the seat numbers p has muted
+func (r *Room) mutedBy(p *Player) []int {
+	numbers := []int{}
+	for _, other := range r.Players {
+		if r.muted[p.ID][other.ID] {
+			numbers = append(numbers, other.Number)
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"time"
+)
+
+// checkCoopComplete ends a co-op game once every word has been found
+func (g *GameState) checkCoopComplete(r *Room) (interface{}, bool) {
+	if len(g.Claimed) < len(g.Words) {
+		return nil, false
+	}
+	return g.coopGameOver(r, true), true
+}
+
+// coopGameOver reports how the team did rather than who won
+func (g *GameState) coopGameOver(r *Room, completed bool) interface{} {
+	g.GameStarted = false
+	g.Winner = ""
+	g.WinningTeam = 0
+
+	return map[string]interface{}{
+		"type": "game_over",
+		"payload": map[string]interface{}{
+			"completed": completed,
+			"completion_time": int(time.Since(g.StartedAt) / time.Second),
+			"time_limit": g.TimeLimit,
+			"found": len(g.Claimed),
+			"total": len(g.Words),
+			"contribution": r.byNumber(g.Score),
+			"unclaimed_words": g.getUnclaimedWordCoords(),
+		},
+	}
+}
+
+// armClock starts the co-op countdown for the game that just started
+func (r *Room) armClock() {
+	r.stopClock()
+
+	if r.GameState.Mode != ModeCoop {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Duration(r.GameState.TimeLimit) * time.Second, func() {
+		r.Do(func() { r.timeUp(timer) })
+	})
+	r.clock = timer
+}
+
+func (r *Room) stopClock() {
+	if r.clock != nil {
+		r.clock.Stop()
+		r.clock = nil
+	}
+}
+
+func (r *Room) timeUp(timer *time.Timer) {
+	if r.clock != timer { // game already over
+		return
+	}
+	r.clock = nil
+
+	g := r.GameState
+	if !g.GameStarted {
+		return
+	}
+	msg := g.coopGameOver(r, false)
+
+	r.logger().Info("co-op time is up")
+
+	r.Broadcast(msg)
+	r.EndRound()
+}
@@ -1,363 +1,454 @@
-package server
-
-import (
-	"sync"
-	"strings"
-	"errors"
-	"math/rand"
-	"log"
-)
-
-type GameState struct {
-	Board [][]rune
-	Words []string				
-	Claimed map[string]string 	// word -> playerID
-	wordCoords map[string]WordCoords
-	Score [2]int
-	GameStarted bool
-	wordlist []string
-	WordCount int
-	GridSize int
-
-	mu sync.Mutex
-}
-
-type Coord struct {
-	Row, Col int
-}
-
-type WordCoords struct {
-    Start [2]int `json:"start"`
-    End   [2]int `json:"end"`
-}
-
-func (g *GameState) getWordFromCoords(start, end Coord) (string, error) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	dRow := end.Row - start.Row
-	dCol := end.Col - start.Col
-
-	lenRow := abs(dRow)
-	lenCol := abs(dCol)
-
-	steps := max(lenRow, lenCol)
-	if steps == 0 { // both 0, single cell
-		return "", errors.New("invalid selection: single cell")
-	}
-
-	if lenRow != 0 && lenCol != 0 && lenRow != lenCol { // diagonal selecton, but length != width, so invalid
-		return "", errors.New("invalid selection: crooked diagonal")
-	}
-
-	stepRow := sign(dRow)
-	stepCol := sign(dCol)
-
-	letters := make([]rune, steps+1)
-	row, col := start.Row, start.Col
-	for i := 0; i <= steps; i++ {
-		if row < 0 || row >= len(g.Board) || col < 0 || col >= len(g.Board[0]){
-			return "", errors.New("section out of bounds")
-		}
-		letters[i] = g.Board[row][col]
-		row += stepRow
-		col += stepCol
-	}
-
-	return strings.ToUpper(string(letters)), nil
-}
-
-func abs(a int) int {
-	return max(a, -a)
-}
-
-func sign(x int) int {
-	switch {
-	case x < 0:
-		return -1
-	case x > 0:
-		return 1
-	default:
-		return 0
-	}
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	} else {
-		return b
-	}
-}
-
-func (g *GameState) ClaimWord(player *Player, start, end Coord, r *Room) (interface{}, error) {
-	word, err := g.getWordFromCoords(start, end)
-
-	if err != nil {
-		return nil, err
-	} else {
-		log.Println("highlighted word: ", word, " reversed: ", reverse(word))
-	}
-
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	if !g.GameStarted {
-		return nil, errors.New("game not started")
-	}
-
-	// check if the word is in the valid list
-	found := false
-	for _, w := range g.Words {
-		if strings.ToUpper(w) == word {
-			found = true
-			break
-		}
-	}
-	// Check if reversed word is in the word list
-	if !found { 
-		word = reverse(word)
-		for _, w := range g.Words {
-		if strings.ToUpper(w) == word {
-			found = true
-			break
-		}
-	}
-	}
-
-	if !found {
-		return nil, errors.New("invalid word")
-	}
-
-	// check if already claimed
-	if _, claimed := g.Claimed[word]; claimed {
-		return nil, errors.New("word already claimed")
-	}
-
-	// claim the word
-	g.Claimed[word] = player.ID
-	if r.Player1.ID == player.ID {
-		g.Score[0] += 1
-	} else if r.Player2.ID == player.ID{
-		g.Score[1] += 1
-	} else {
-		return nil, errors.New("invalid player ID on word claim")
-	}
-
-	// broadcast to both players
-	msg := map[string]interface{}{
-		"type": "word_claimed",
-		"payload": map[string]interface{}{
-			"word": word,
-			"player_number": player.Number,
-			"start": [2]int{start.Row, start.Col},
-			"end": [2]int{end.Row, end.Col},
-			"score": g.Score,
-		},
-	}
-
-	return msg, nil
-}
-
-func reverse(s string) string {
-    runes := []rune(s)
-    for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-        runes[i], runes[j] = runes[j], runes[i]
-    }
-    return string(runes)
-}
-
-func (g *GameState) CheckForWinner(r *Room) (interface{}, bool) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	var msg interface{}
-	if g.Score[0] >= len(g.Words)/2 + 1 { // Player 1 wins!
-		r.Player1.mu.Lock()
-		defer r.Player1.mu.Unlock()
-
-		msg = map[string]interface{}{
-			"type": "game_over",
-			"payload": map[string]interface{}{
-				"winner": r.Player1.Number,
-				"unclaimed_words": g.getUnclaimedWordCoords(),
-			},
-		}
-	} else if g.Score[1] >= len(g.Words)/2 + 1 { // Player 2 wins!
-		r.Player2.mu.Lock()
-		defer r.Player2.mu.Unlock()
-
-		msg = map[string]interface{}{
-			"type": "game_over",
-			"payload": map[string]interface{}{
-				"winner": r.Player2.Number,
-				"unclaimed_words": g.getUnclaimedWordCoords(),
-			},
-		}
-	} else {
-		return nil, false
-	}
-
-	g.GameStarted = false
-	return msg, true
-}
-
-func (g *GameState) getUnclaimedWordCoords() []WordCoords {
-	var unclaimedWords []WordCoords
-
-	for _, word := range g.Words {
-		word = strings.ToUpper(word)
-        if _, ok := g.Claimed[word]; !ok {
-            unclaimedWords = append(unclaimedWords, g.wordCoords[word])
-        }
-    }
-
-    return unclaimedWords
-}
-
-
-func (g *GameState) StartGame() interface{} {
-	g.Claimed = make(map[string]string)
-	g.GameStarted = true
-	g.Score = [2]int{0, 0}
-	g.Words = g.getRandomWords(g.WordCount)
-	g.Board = g.generateBoard(g.GridSize, g.Words)
-
-	return map[string]interface{}{
-		"type": "game_start",
-		"payload": map[string]interface{}{
-			"board": g.Board,
-			"words": g.Words,
-		},
-	}
-}
-
-func (g *GameState) getRandomWords(n int) []string {
-	indices := make([]int, 0, n)
-	words := make([]string, 0, n)
-	seen := make(map[int]struct{})
-
-	for len(indices) < n {
-		num := rand.Intn(len(g.wordlist))
-		if _, ok := seen[num]; !ok {
-			seen[num] = struct{}{}
-			indices = append(indices, num)
-		}
-	}
-
-	for _, i := range indices {
-		words = append(words, g.wordlist[i])
-	}
-
-	return words
-}
-
-func (g *GameState) generateBoard(GridSize int, words []string) [][]rune {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	// Initialize Board
-	board := make([][]rune, GridSize)
-	for i := range board {
-		board[i] = make([]rune, GridSize)
-		for j := range board[i] {
-			board[i][j] = 0 // empty
-		}
-	}
-
-	// Initialize map
-	g.wordCoords = make(map[string]WordCoords, len(words))
-
-	// Place words randomly
-	for _, word := range words {
-		word = strings.ToUpper(string(word))
-		success := false
-		attempts := 0
-
-		for !success && attempts < 100 {
-			attempts++
-
-			dirRow := rand.Intn(3) - 1 // -1, 0, 1
-			dirCol := rand.Intn(3) - 1 // -1, 0, 1
-
-			// skip zero direction
-			if dirRow == 0 && dirCol == 0 {
-				continue
-			}
-
-			// choose random starting cell
-			maxRow := GridSize - 1
-			maxCol := GridSize - 1
-
-			var startRow int
-			var startCol int
-
-			log.Println(maxRow, maxCol, dirRow, dirCol, len(word), word)
-
-			if dirRow == 1 {
-				startRow = rand.Intn(maxRow - len(word) + 1)
-			} else if dirRow == 0 {
-				startRow = rand.Intn(maxRow + 1)
-			} else { // dirRow == -1
-				startRow = rand.Intn(maxRow - len(word) + 1) + len(word) - 1
-			}
-
-			if dirCol == 1 {
-				startCol = rand.Intn(maxCol - len(word) + 1)
-			} else if dirCol == 0 {
-				startCol = rand.Intn(maxCol + 1)
-			} else { // dirCol == -1
-				startCol = rand.Intn(maxCol - len(word) + 1) + len(word) - 1
-			}
-
-			// check if the word fits
-			row, col := startRow, startCol
-			canPlace := true
-			for _, c := range word {
-				if row < 0 || row >= GridSize || col < 0 || col >= GridSize {
-					canPlace = false
-					break
-				}
-				if board[row][col] != 0 && board[row][col] != c {
-					canPlace = false
-					break
-				}
-				row += dirRow
-				col += dirCol
-			}
-
-			if !canPlace {
-				continue
-			}
-
-			// place the word
-			row, col = startRow, startCol
-			startCoord := [2]int{row, col}
-			for _, c := range word {
-				board[row][col] = c
-				row += dirRow
-				col += dirCol
-			}
-			endCoord := [2]int{row-dirRow, col-dirCol}
-			g.wordCoords[word] = WordCoords{startCoord, endCoord}
-
-			success = true
-		}
-		// Possible but very unlikely to not place a word
-	}
-
-	// Fill empty cells with random letters
-	for i := 0; i < GridSize; i++ {
-		for j := 0; j < GridSize; j++ {
-			if board[i][j] == 0 {
-				board[i][j] = randomLetter()
-			}
-		}
-	}
-
-	return board
-}
-
-func randomLetter() rune {
-	return rune('A' + rand.Intn(26)) // 26 letters
+package server
+
+import (
+	"strings"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Game modes
+const (
+	ModeLockout = "lockout"		// every player for themselves
+	ModeTeams = "teams"			// teams share a score pool
+	ModeCoop = "coop"			// everyone races the clock together
+)
+
+func ValidMode(mode string) bool {
+	return mode == ModeLockout || mode == ModeTeams || mode == ModeCoop
+}
+
+var errWordClaimed = errors.New("word already claimed")
+
+type GameState struct {
+	Board [][]rune
+	Words []string				
+	Claimed map[string]string 	// word -> playerID
+	wordCoords map[string]WordCoords
+	Score map[string]int		// playerID -> words claimed, minus penalties
+	GameStarted bool
+	Winner string 		// playerID of the last game's winner, empty for a draw
+	TeamScore map[int]int	// team -> words claimed, teams mode only
+	WinningTeam int
+	StartedAt time.Time
+
+	// settings of the current or last game, copied from the room at the start
+	Mode string
+	TimeLimit int		// seconds, co-op only
+	wordlist []string
+	directions [][2]int
+	WordCount int
+	GridSize int
+}
+
+type Coord struct {
+	Row, Col int
+}
+
+type WordCoords struct {
+    Start [2]int `json:"start"`
+    End   [2]int `json:"end"`
+}
+
+func (g *GameState) getWordFromCoords(start, end Coord) (string, error) {
+	dRow := end.Row - start.Row
+	dCol := end.Col - start.Col
+
+	lenRow := abs(dRow)
+	lenCol := abs(dCol)
+
+	steps := max(lenRow, lenCol)
+	if steps == 0 { // both 0, single cell
+		return "", errors.New("invalid selection: single cell")
+	}
+
+	if lenRow != 0 && lenCol != 0 && lenRow != lenCol { // diagonal selecton, but length != width, so invalid
+		return "", errors.New("invalid selection: crooked diagonal")
+	}
+
+	stepRow := sign(dRow)
+	stepCol := sign(dCol)
+
+	letters := make([]rune, steps+1)
+	row, col := start.Row, start.Col
+	for i := 0; i <= steps; i++ {
+		if row < 0 || row >= len(g.Board) || col < 0 || col >= len(g.Board[0]){
+			return "", errors.New("section out of bounds")
+		}
+		letters[i] = g.Board[row][col]
+		row += stepRow
+		col += stepCol
+	}
+
+	return strings.ToUpper(string(letters)), nil
+}
+
+func abs(a int) int {
+	return max(a, -a)
+}
+
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
+
+func (g *GameState) ClaimWord(player *Player, start, end Coord, r *Room) (interface{}, error) {
+	word, err := g.getWordFromCoords(start, end)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !g.GameStarted {
+		return nil, errors.New("game not started")
+	}
+
+	// check if the word is in the valid list
+	found := false
+	for _, w := range g.Words {
+		if strings.ToUpper(w) == word {
+			found = true
+			break
+		}
+	}
+	// Check if reversed word is in the word list
+	if !found { 
+		word = reverse(word)
+		for _, w := range g.Words {
+		if strings.ToUpper(w) == word {
+			found = true
+			break
+		}
+	}
+	}
+
+	if !found {
+		return nil, errors.New("invalid word")
+	}
+
+	// check if already claimed
+	if _, claimed := g.Claimed[word]; claimed {
+		return nil, errWordClaimed
+	}
+
+	// claim the word
+	if _, ok := g.Score[player.ID]; !ok {
+		return nil, errors.New("invalid player ID on word claim")
+	}
+	g.Claimed[word] = player.ID
+	g.Score[player.ID] += 1
+	if g.Mode == ModeTeams {
+		g.TeamScore[player.Team] += 1
+	}
+
+	// broadcast to all players
+	payload := map[string]interface{}{
+		"word": word,
+		"player_number": player.Number,
+		"start": [2]int{start.Row, start.Col},
+		"end": [2]int{end.Row, end.Col},
+		"score": r.byNumber(g.Score),
+	}
+	if g.Mode == ModeTeams {
+		payload["team"] = player.Team
+		payload["team_score"] = g.teamScore()
+	}
+	msg := map[string]interface{}{
+		"type": "word_claimed",
+		"payload": payload,
+	}
+
+	return msg, nil
+}
+
+func reverse(s string) string {
+    runes := []rune(s)
+    for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+        runes[i], runes[j] = runes[j], runes[i]
+    }
+    return string(runes)
+}
+
+// endReason says how the last game ended, for the metrics
+func (g *GameState) endReason(abandoned bool) string {
+	switch {
+	case abandoned:
+		return "abandoned"
+	case g.Mode == ModeCoop && len(g.Claimed) == len(g.Words):
+		return "completed"
+	case g.Mode == ModeCoop:
+		return "time_up"
+	case g.Winner != "" || g.WinningTeam != 0:
+		return "won"
+	}
+	return "draw"
+}
+
+// CheckForWinner ends the game once no one can catch the leader with the
+// words that are left, or as a draw when the board is cleared on a tie.
+func (g *GameState) CheckForWinner(r *Room) (interface{}, bool) {
+	if g.Mode == ModeTeams {
+		return g.checkTeamWinner(r)
+	}
+	if g.Mode == ModeCoop {
+		return g.checkCoopComplete(r)
+	}
+
+	var leader *Player
+	best, runnerUp := -1, -1
+	for _, p := range r.Players {
+		score := g.Score[p.ID]
+		if score > best {
+			leader = p
+			runnerUp = best
+			best = score
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+	remaining := len(g.Words) - len(g.Claimed)
+
+	if leader != nil && best > runnerUp + remaining {
+		g.Winner = leader.ID
+	} else if remaining == 0 {
+		leader = nil
+		g.Winner = ""
+	} else {
+		return nil, false
+	}
+
+	winner := 0
+	if leader != nil {
+		winner = leader.Number
+	}
+
+	msg := map[string]interface{}{
+		"type": "game_over",
+		"payload": map[string]interface{}{
+			"winner": winner,		// 0 on a draw
+			"score": r.byNumber(g.Score),
+			"unclaimed_words": g.getUnclaimedWordCoords(),
+		},
+	}
+
+	g.GameStarted = false
+	return msg, true
+}
+
+func (g *GameState) getUnclaimedWordCoords() []WordCoords {
+	var unclaimedWords []WordCoords
+
+	for _, word := range g.Words {
+		word = strings.ToUpper(word)
+        if _, ok := g.Claimed[word]; !ok {
+            unclaimedWords = append(unclaimedWords, g.wordCoords[word])
+        }
+    }
+
+    return unclaimedWords
+}
+
+
+func (g *GameState) StartGame(players []*Player) interface{} {
+	g.Claimed = make(map[string]string)
+	g.GameStarted = true
+	g.Score = make(map[string]int, len(players))
+	g.TeamScore = make(map[int]int)
+	g.WinningTeam = 0
+	for _, p := range players {
+		g.Score[p.ID] = 0
+		if g.Mode == ModeTeams {
+			g.TeamScore[p.Team] = 0
+		}
+	}
+	g.Words = g.getRandomWords(g.WordCount)
+	started := time.Now()
+	g.Board = g.generateBoard(g.GridSize, g.Words)
+	boardGeneration.Observe(time.Since(started).Seconds())
+	g.StartedAt = time.Now()
+
+	payload := map[string]interface{}{
+		"board": g.Board,
+		"words": g.Words,
+	}
+	if g.Mode == ModeCoop {
+		payload["time_limit"] = g.TimeLimit
+	}
+
+	return map[string]interface{}{
+		"type": "game_start",
+		"payload": payload,
+	}
+}
+
+// getRandomWords picks n different words short enough for the grid.
+// CheckBoard makes sure there are enough of them.
+func (g *GameState) getRandomWords(n int) []string {
+	pool := fitting(g.wordlist, g.GridSize)
+	if n > len(pool) {
+		n = len(pool)
+	}
+
+	words := make([]string, 0, n)
+	for _, i := range rand.Perm(len(pool))[:n] {
+		words = append(words, pool[i])
+	}
+
+	return words
+}
+
+// fitting returns the words that fit a gridSize board in a straight line
+func fitting(words []string, gridSize int) []string {
+	var out []string
+	for _, w := range words {
+		if n := len([]rune(w)); n > 1 && n <= gridSize {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// checkBoard makes sure wordCount words from the list can be hidden in a
+// gridSize board without taking up more than fill of its cells
+func checkBoard(words []string, wordCount, gridSize int, fill float64) error {
+	pool := fitting(words, gridSize)
+	if wordCount > len(pool) {
+		return fmt.Errorf("only %d words fit a %dx%d grid", len(pool), gridSize, gridSize)
+	}
+
+	letters := 0
+	for _, w := range pool {
+		letters += len([]rune(w))
+	}
+	average := float64(letters) / float64(len(pool))
+	if float64(wordCount) * average > fill * float64(gridSize*gridSize) {
+		return fmt.Errorf("too many words for a %dx%d grid", gridSize, gridSize)
+	}
+	return nil
+}
+
+func (g *GameState) generateBoard(GridSize int, words []string) [][]rune {
+	// Initialize Board
+	board := make([][]rune, GridSize)
+	for i := range board {
+		board[i] = make([]rune, GridSize)
+		for j := range board[i] {
+			board[i][j] = 0 // empty
+		}
+	}
+
+	// Initialize map
+	g.wordCoords = make(map[string]WordCoords, len(words))
+
+	// Place words randomly
+	total := 0
+	defer func() { boardAttempts.Observe(float64(total)) }()
+
+	for _, word := range words {
+		word = strings.ToUpper(string(word))
+		letters := []rune(word) // lengths in cells, not bytes
+		success := false
+		attempts := 0
+
+		for !success && attempts < 100 {
+			attempts++
+			total++
+
+			dir := g.directions[rand.Intn(len(g.directions))]
+			dirRow, dirCol := dir[0], dir[1]
+
+			// choose random starting cell
+			maxRow := GridSize - 1
+			maxCol := GridSize - 1
+
+			// a word of n letters fits from this many starting rows or columns
+			span := GridSize - len(letters) + 1
+
+			var startRow int
+			var startCol int
+
+			if dirRow == 1 {
+				startRow = rand.Intn(span)
+			} else if dirRow == 0 {
+				startRow = rand.Intn(maxRow + 1)
+			} else { // dirRow == -1
+				startRow = rand.Intn(span) + len(letters) - 1
+			}
+
+			if dirCol == 1 {
+				startCol = rand.Intn(span)
+			} else if dirCol == 0 {
+				startCol = rand.Intn(maxCol + 1)
+			} else { // dirCol == -1
+				startCol = rand.Intn(span) + len(letters) - 1
+			}
+
+			// check if the word fits
+			row, col := startRow, startCol
+			canPlace := true
+			for _, c := range letters {
+				if row < 0 || row >= GridSize || col < 0 || col >= GridSize {
+					canPlace = false
+					break
+				}
+				if board[row][col] != 0 && board[row][col] != c {
+					canPlace = false
+					break
+				}
+				row += dirRow
+				col += dirCol
+			}
+
+			if !canPlace {
+				continue
+			}
+
+			// place the word
+			row, col = startRow, startCol
+			startCoord := [2]int{row, col}
+			for _, c := range letters {
+				board[row][col] = c
+				row += dirRow
+				col += dirCol
+			}
+			endCoord := [2]int{row-dirRow, col-dirCol}
+			g.wordCoords[word] = WordCoords{startCoord, endCoord}
+
+			success = true
+		}
+		// Possible but very unlikely to not place a word
+		if !success {
+			wordsUnplaced.Inc()
+		}
+	}
+
+	// Fill empty cells with random letters
+	for i := 0; i < GridSize; i++ {
+		for j := 0; j < GridSize; j++ {
+			if board[i][j] == 0 {
+				board[i][j] = randomLetter()
+			}
+		}
+	}
+
+	return board
+}
+
+func randomLetter() rune {
+	return rune('A' + rand.Intn(26)) // 26 letters
 }
\ No newline at end of file
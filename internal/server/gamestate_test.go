@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// longer in bytes than in letters, the longest ones fill a 12x12 row
+var umlautWords = []string{
+	"Käsebrötchen", "Überraschung", "Schlüssel", "Löwenzahn", "Brücke",
+	"Fähre", "Müllerin", "Äpfelchen", "Übermut", "Gemüse",
+}
+
+func TestGenerateBoardMultiByteWords(t *testing.T) {
+	if err := checkBoard(umlautWords, 5, 12, 0.5); err != nil {
+		t.Fatalf("checkBoard: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		g := &GameState{}
+		g.configure(RoomSettings{WordCount: 5, GridSize: 12, Directions: DirectionsAll}, umlautWords)
+		g.Words = g.getRandomWords(g.WordCount)
+		g.Board = g.generateBoard(g.GridSize, g.Words)
+
+		for word, coords := range g.wordCoords {
+			start := Coord{coords.Start[0], coords.Start[1]}
+			end := Coord{coords.End[0], coords.End[1]}
+			got, err := g.getWordFromCoords(start, end)
+			if err != nil {
+				t.Fatalf("%s at %v: %v", word, coords, err)
+			}
+			if got != word && reverse(got) != word {
+				t.Fatalf("board reads %q where %q was placed", got, word)
+			}
+		}
+	}
+}
+
+// testGame is a running game with words placed nowhere, scores are set by hand
+func testGame(mode string, words int, players ...*Player) (*Room, *GameState) {
+	g := &GameState{
+		Mode: mode,
+		GameStarted: true,
+		Claimed: make(map[string]string),
+		Score: make(map[string]int),
+		TeamScore: make(map[int]int),
+	}
+	for i := 0; i < words; i++ {
+		g.Words = append(g.Words, fmt.Sprint("word", i))
+	}
+	for i, p := range players {
+		p.Number = i + 1
+		g.Score[p.ID] = 0
+	}
+	return &Room{Players: players, GameState: g}, g
+}
+
+// claim credits the next unclaimed word to p
+func (g *GameState) claim(p *Player) {
+	word := strings.ToUpper(g.Words[len(g.Claimed)])
+	g.Claimed[word] = p.ID
+	g.Score[p.ID]++
+	if g.Mode == ModeTeams {
+		g.TeamScore[p.Team]++
+	}
+}
+
+func testPlayers(n int) []*Player {
+	players := make([]*Player, n)
+	for i := range players {
+		players[i] = NewPlayer(fmt.Sprint("p", i+1), nil, 16)
+	}
+	return players
+}
+
+func TestCheckForWinner(t *testing.T) {
+	tests := []struct {
+		name string
+		players int
+		words int
+		claims []int	// player index per claimed word
+		over bool
+		winner int		// player number, 0 = draw
+	}{
+		{"no claims", 2, 5, nil, false, 0},
+		{"can still be caught", 2, 5, []int{0, 0, 1}, false, 0},
+		{"out of reach", 2, 5, []int{0, 0, 0}, true, 1},
+		{"second player wins", 2, 7, []int{1, 0, 1, 1, 1}, true, 2},
+		{"tie on a cleared board", 2, 4, []int{0, 1, 0, 1}, true, 0},
+		{"three players, catchable", 3, 5, []int{0, 0, 1, 2}, false, 0},
+		{"three players, out of reach", 3, 5, []int{0, 0, 0, 1}, true, 1},
+		{"three players, cleared", 3, 6, []int{0, 1, 2, 0, 1, 2}, true, 0},
+	}
+	for _, tt := range tests {
+		players := testPlayers(tt.players)
+		r, g := testGame(ModeLockout, tt.words, players...)
+		for _, i := range tt.claims {
+			g.claim(players[i])
+		}
+
+		msg, over := g.CheckForWinner(r)
+		if over != tt.over {
+			t.Errorf("%s: over = %v", tt.name, over)
+			continue
+		}
+		if !over {
+			if msg != nil || !g.GameStarted {
+				t.Errorf("%s: game ended without a game_over", tt.name)
+			}
+			continue
+		}
+
+		payload := msg.(map[string]interface{})["payload"].(map[string]interface{})
+		if payload["winner"] != tt.winner {
+			t.Errorf("%s: winner = %v, want %d", tt.name, payload["winner"], tt.winner)
+		}
+		if g.GameStarted {
+			t.Errorf("%s: game still running", tt.name)
+		}
+		if tt.winner == 0 && g.Winner != "" || tt.winner != 0 && g.Winner != players[tt.winner-1].ID {
+			t.Errorf("%s: Winner = %q", tt.name, g.Winner)
+		}
+	}
+}
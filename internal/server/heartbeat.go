@@ -0,0 +1,71 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Heartbeat controls the liveness checks on a player's connection. The
+// server pings every PingInterval and drops the connection when no pong (or
+// any other frame) arrives within PongWait. Each write must finish within
+// WriteWait.
+type Heartbeat struct {
+	PingInterval time.Duration
+	PongWait time.Duration
+	WriteWait time.Duration
+}
+
+// ExtendReadDeadline gives the peer another PongWait to show it's alive
+func (p *Player) ExtendReadDeadline(hb Heartbeat) {
+	p.Conn.SetReadDeadline(time.Now().Add(hb.PongWait))
+}
+
+// Touch marks the player as active, see StartIdleReaper
+func (p *Player) Touch() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastActive = time.Now()
+}
+
+func (p *Player) idleSince() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lastActive
+}
+
+func (p *Player) ping(hb Heartbeat) error {
+	p.Conn.SetWriteDeadline(time.Now().Add(hb.WriteWait))
+	return p.Conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// StartIdleReaper disconnects players that haven't sent a message within
+// timeout. Their read pumps then clean up rooms as usual.
+func (s *Server) StartIdleReaper(timeout time.Duration) {
+	go func() {
+		ticker := time.NewTicker(timeout / 4)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			for _, p := range s.idlePlayers(timeout) {
+				p.Logger().Info("evicting idle player")
+				p.Disconnect()
+			}
+		}
+	}()
+}
+
+func (s *Server) idlePlayers(timeout time.Duration) []*Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var idle []*Player
+	for _, p := range s.players {
+		if time.Since(p.idleSince()) > timeout {
+			idle = append(idle, p)
+		}
+	}
+	return idle
+}
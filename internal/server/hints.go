@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+)
+
+// What a hint reveals about a word
+const (
+	HintFirstLetter = "first_letter"	// the cell of its first letter
+	HintDirection = "direction"			// which way it runs
+	HintRegion = "region"				// the third of the grid, both ways, it starts in
+)
+
+var directionNames = map[[2]int]string{
+	{0, 1}: "right", {0, -1}: "left", {1, 0}: "down", {-1, 0}: "up",
+	{1, 1}: "down_right", {1, -1}: "down_left", {-1, 1}: "up_right", {-1, -1}: "up_left",
+}
+
+// Hint reveals something about an unclaimed word to p only, word may be empty
+// to let the server pick one. The rest of the room learns that a hint was used.
+func (r *Room) Hint(p *Player, kind, word string) error {
+	g := r.GameState
+	if !g.GameStarted {
+		return errors.New("game not started")
+	}
+	if r.Settings.Hints == 0 {
+		return errors.New("hints are turned off")
+	}
+	if r.hintsLeft(p) <= 0 {
+		return errors.New("no hints left")
+	}
+	if kind != HintFirstLetter && kind != HintDirection && kind != HintRegion {
+		return errors.New("invalid hint. must be first_letter, direction or region.")
+	}
+
+	word, err := g.hintWord(word)
+	if err != nil {
+		return err
+	}
+
+	r.hintsUsed[p.ID]++
+	left := r.hintsLeft(p)
+	points := g.deduct(p, r.Settings.HintCost)
+
+	coords := g.wordCoords[word]
+	hint := map[string]interface{}{
+		"kind": kind,
+		"word": word,
+		"hints_left": left,
+		"points": points,
+	}
+	switch kind {
+	case HintFirstLetter:
+		hint["cell"] = coords.Start
+	case HintDirection:
+		hint["direction"] = directionNames[[2]int{sign(coords.End[0] - coords.Start[0]), sign(coords.End[1] - coords.Start[1])}]
+	case HintRegion:
+		hint["rows"] = g.third(coords.Start[0])
+		hint["cols"] = g.third(coords.Start[1])
+	}
+	p.Send(map[string]interface{}{
+		"type": "hint",
+		"payload": hint,
+	})
+
+	notice := map[string]interface{}{
+		"player_number": p.Number,
+		"hints_left": left,
+		"points": points,
+		"score": r.byNumber(g.Score),
+	}
+	if g.Mode == ModeTeams {
+		notice["team_score"] = g.teamScore()
+	}
+	for _, other := range r.Players {
+		if other != p {
+			other.Send(map[string]interface{}{
+				"type": "hint_used",
+				"payload": notice,
+			})
+		}
+	}
+	return nil
+}
+
+// hintWord returns word in the form the board uses, or a random unclaimed
+// word when it's empty
+func (g *GameState) hintWord(word string) (string, error) {
+	if word != "" {
+		word = strings.ToUpper(word)
+		if _, ok := g.wordCoords[word]; !ok {
+			return "", errors.New("not a word in this game")
+		}
+		if _, claimed := g.Claimed[word]; claimed {
+			return "", errWordClaimed
+		}
+		return word, nil
+	}
+
+	var unclaimed []string
+	for _, w := range g.Words {
+		w = strings.ToUpper(w)
+		if _, claimed := g.Claimed[w]; !claimed {
+			if _, placed := g.wordCoords[w]; placed {
+				unclaimed = append(unclaimed, w)
+			}
+		}
+	}
+	if len(unclaimed) == 0 {
+		return "", errors.New("no words left to hint")
+	}
+	return unclaimed[rand.Intn(len(unclaimed))], nil
+}
+
+// third is the first and last row (or column) of the third of the grid i is in
+func (g *GameState) third(i int) [2]int {
+	size := len(g.Board)
+	from := 0
+	for part := 1; part <= 3; part++ {
+		to := part * size / 3
+		if i < to {
+			return [2]int{from, to - 1}
+		}
+		from = to
+	}
+	return [2]int{from, size - 1}
+}
+
+// hintsLeft is how many hints p can still use in the running game
+func (r *Room) hintsLeft(p *Player) int {
+	return r.Settings.Hints - r.hintsUsed[p.ID]
+}
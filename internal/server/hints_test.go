@@ -0,0 +1,97 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThird(t *testing.T) {
+	tests := []struct {
+		size int
+		i int
+		want [2]int
+	}{
+		{9, 0, [2]int{0, 2}},
+		{9, 8, [2]int{6, 8}},
+		{10, 3, [2]int{3, 5}},
+		{10, 9, [2]int{6, 9}},
+		{11, 6, [2]int{3, 6}},
+		{11, 7, [2]int{7, 10}},
+	}
+	for _, tt := range tests {
+		g := &GameState{Board: make([][]rune, tt.size)}
+		if got := g.third(tt.i); got != tt.want {
+			t.Errorf("third(%d) on %d cells = %v, want %v", tt.i, tt.size, got, tt.want)
+		}
+	}
+
+	// every cell falls in a region inside the board
+	for size := 5; size <= 20; size++ {
+		g := &GameState{Board: make([][]rune, size)}
+		for i := 0; i < size; i++ {
+			if got := g.third(i); got[0] > i || got[1] < i || got[0] < 0 || got[1] >= size {
+				t.Errorf("third(%d) on %d cells = %v", i, size, got)
+			}
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	r := testRoom(t)
+	players := testPlayers(2)
+	p := players[0]
+	r.Do(func() {
+		for _, player := range players {
+			r.AddPlayer(player)
+		}
+		if r.Hint(p, HintFirstLetter, "") == nil {
+			t.Errorf("hint before the game")
+		}
+
+		r.Settings.Hints = 2
+		r.Settings.HintCost = 2
+		r.StartGame()
+		g := r.GameState
+		g.Score[p.ID] = 1
+
+		claimed := strings.ToUpper(g.Words[0])
+		g.Claimed[claimed] = players[1].ID
+		for _, tt := range []struct{ kind, word string }{
+			{"everything", ""},
+			{HintRegion, "notaword"},
+			{HintRegion, claimed},
+		} {
+			if r.Hint(p, tt.kind, tt.word) == nil {
+				t.Errorf("hint %s for %q accepted", tt.kind, tt.word)
+			}
+		}
+
+		word := strings.ToUpper(g.Words[1])
+		if err := r.Hint(p, HintRegion, strings.ToLower(word)); err != nil {
+			t.Errorf("hint: %v", err)
+			return
+		}
+		hint := received(p, "hint")
+		start := g.wordCoords[word].Start
+		rows, cols := hint["rows"].([2]int), hint["cols"].([2]int)
+		if start[0] < rows[0] || start[0] > rows[1] || start[1] < cols[0] || start[1] > cols[1] {
+			t.Errorf("%s starts at %v, outside rows %v cols %v", word, start, rows, cols)
+		}
+		if hint["points"] != 1 || g.Score[p.ID] != 0 {
+			t.Errorf("took %v points, score %d, want 1 and 0", hint["points"], g.Score[p.ID])
+		}
+		if used := received(players[1], "hint_used"); used == nil || used["hints_left"] != 1 {
+			t.Errorf("hint_used = %v", used)
+		}
+
+		if err := r.Hint(p, HintDirection, ""); err != nil {
+			t.Errorf("second hint: %v", err)
+		}
+		if received(p, "hint")["points"] != 0 {
+			t.Errorf("points taken below zero")
+		}
+		if r.Hint(p, HintFirstLetter, "") == nil {
+			t.Errorf("hint past the limit")
+		}
+	})
+}
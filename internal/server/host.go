@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+)
+
+// IsHost reports whether p may change settings and use the host controls
+func (r *Room) IsHost(p *Player) bool {
+	return p.ID == r.HostID
+}
+
+func (r *Room) playerByNumber(n int) *Player {
+	for _, p := range r.Players {
+		if p.Number == n {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *Room) setHost(p *Player, reason string) {
+	r.HostID = p.ID
+	r.Broadcast(map[string]interface{}{
+		"type": "host_changed",
+		"payload": map[string]interface{}{
+			"host": p.Number,
+			"reason": reason,
+			"players": r.PlayerList(),
+		},
+	})
+}
+
+// TransferHost hands the host controls to the player in seat number
+func (r *Room) TransferHost(host *Player, number int) error {
+	if !r.IsHost(host) {
+		return errors.New("only the host can transfer the room")
+	}
+
+	target := r.playerByNumber(number)
+	if target == nil {
+		return errors.New("no such player")
+	}
+	if target == host {
+		return errors.New("already the host")
+	}
+
+	r.setHost(target, "transferred")
+	return nil
+}
+
+// Kick removes the player in seat number from the room, they can't join it again
+func (r *Room) Kick(host *Player, number int) error {
+	if !r.IsHost(host) {
+		return errors.New("only the host can kick players")
+	}
+
+	target := r.playerByNumber(number)
+	if target == nil {
+		return errors.New("no such player")
+	}
+	if target == host {
+		return errors.New("can't kick yourself")
+	}
+
+	r.banned[target.ID] = true
+	r.RemovePlayer(target)
+	target.Send(map[string]interface{}{
+		"type": "kicked",
+		"payload": map[string]interface{}{
+			"reason": "removed by the host",
+		},
+	})
+	return nil
+}
+
+// SetLocked stops or allows new players joining
+func (r *Room) SetLocked(host *Player, locked bool) error {
+	if !r.IsHost(host) {
+		return errors.New("only the host can lock the room")
+	}
+
+	r.Locked = locked
+	r.Broadcast(map[string]interface{}{
+		"type": "room_locked",
+		"payload": map[string]interface{}{
+			"locked": locked,
+		},
+	})
+	return nil
+}
+
+// RegenerateCode gives the player's room a new join code, the old one stops
+// working straight away
+func (s *Server) RegenerateCode(p *Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := p.CurrentRoom()
+	if room == nil {
+		return errors.New("not in a game")
+	}
+
+	var err error
+	ok := room.Do(func() {
+		if p.CurrentRoom() != room {
+			err = errors.New("not in a game")
+			return
+		}
+		if !room.IsHost(p) {
+			err = errors.New("only the host can change the join code")
+			return
+		}
+
+		code := s.newCode()
+		delete(s.codes, room.JoinCode)
+		s.codes[code] = room
+		room.JoinCode = code
+
+		room.Touch()
+		room.Broadcast(map[string]interface{}{
+			"type": "code_changed",
+			"payload": map[string]interface{}{
+				"code": code,
+			},
+		})
+	})
+	if !ok {
+		return errors.New("not in a game")
+	}
+	return err
+}
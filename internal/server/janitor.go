@@ -0,0 +1,81 @@
+package server
+
+import (
+	"time"
+)
+
+// Touch marks the room as active, pushing back its idle expiry
+func (r *Room) Touch() {
+	r.lastActivity = time.Now()
+	r.expiryWarned = false
+}
+
+// idleTimeout is how long the room may sit idle in its current state, 0 while
+// a game or series is running
+func (r *Room) idleTimeout() time.Duration {
+	switch {
+	case r.GameState.GameStarted || r.InIntermission():
+		return 0
+	case !r.finishedAt.IsZero():
+		return r.cfg.PostGameTimeout.Duration
+	}
+	return r.cfg.LobbyTimeout.Duration
+}
+
+// checkExpiry warns the players once the room is about to expire and reports
+// whether it has
+func (r *Room) checkExpiry(now time.Time) bool {
+	timeout := r.idleTimeout()
+	if timeout == 0 {
+		return false
+	}
+
+	left := r.lastActivity.Add(timeout).Sub(now)
+	if left <= 0 {
+		return true
+	}
+
+	if left <= r.cfg.ExpiryWarning.Duration && !r.expiryWarned {
+		r.expiryWarned = true
+		r.Broadcast(map[string]interface{}{
+			"type": "room_expiring",
+			"payload": map[string]interface{}{
+				"seconds_left": int(left / time.Second),
+			},
+		})
+	}
+	return false
+}
+
+// StartJanitor closes rooms that have been idle in the lobby or after a game
+// for too long, releasing their join codes
+func (s *Server) StartJanitor() {
+	interval := s.cfg.Rooms.ExpiryWarning.Duration / 4
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			s.expireRooms(now)
+		}
+	}()
+}
+
+func (s *Server) expireRooms(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, room := range s.rooms {
+		expired := false
+		room.Do(func() {
+			if expired = room.checkExpiry(now); expired {
+				room.closeAll("closed for inactivity")
+			}
+		})
+
+		if expired {
+			s.removeRoom(room)
+			room.logger().Info("room expired")
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		started bool
+		finished bool
+		expires bool
+	}{
+		{"lobby", false, false, true},
+		{"after a game", false, true, true},
+		{"game running", true, true, false},
+	}
+	for _, tt := range tests {
+		r := testRoom(t)
+		p := NewPlayer("p1", nil, 16)
+		r.Do(func() {
+			r.AddPlayer(p)
+			r.GameState.GameStarted = tt.started
+			if tt.finished {
+				r.finishedAt = time.Now()
+			}
+			timeout := r.cfg.LobbyTimeout.Duration
+			if tt.finished {
+				timeout = r.cfg.PostGameTimeout.Duration
+			}
+			warning := r.cfg.ExpiryWarning.Duration
+			idle := r.lastActivity
+
+			steps := []struct {
+				after time.Duration
+				warned bool
+				expired bool
+			}{
+				{timeout - 2*warning, false, false},
+				{timeout - warning/2, true, false},
+				{timeout - warning/4, false, false},	// warned once only
+				{timeout, false, true},
+			}
+			for _, step := range steps {
+				expired := r.checkExpiry(idle.Add(step.after))
+				warned := received(p, "room_expiring") != nil
+				if !tt.expires {
+					if expired || warned {
+						t.Errorf("%s: expired %v, warned %v while playing", tt.name, expired, warned)
+					}
+					continue
+				}
+				if expired != step.expired || warned != step.warned {
+					t.Errorf("%s after %v: expired %v, warned %v", tt.name, step.after, expired, warned)
+				}
+			}
+
+			// any activity starts the clock again, with a fresh warning
+			r.Touch()
+			if tt.expires && (r.checkExpiry(time.Now().Add(timeout-warning/2)) || received(p, "room_expiring") == nil) {
+				t.Errorf("%s: no new warning after activity", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"sync"
+	"time"
+	"github.com/google/uuid"
+)
+
+// MatchRecord is what the server remembers about one game
+type MatchRecord struct {
+	ID string `json:"id"`
+	RoomID string `json:"room_id"`
+	Mode string `json:"mode"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt time.Time `json:"ended_at,omitempty"`
+	Abandoned bool `json:"abandoned"`	// someone left before the end
+
+	Players []MatchPlayer `json:"players"`
+	Winner string `json:"winner,omitempty"`		// playerID, empty for a draw or no winner
+	WinningTeam int `json:"winning_team,omitempty"`
+
+	Flags []Flag `json:"flags"`
+	Chat []ChatLine `json:"chat"`		// from the start until the next game, see Room.Chat
+}
+
+type MatchPlayer struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	Number int `json:"number"`
+	Team int `json:"team,omitempty"`
+	Score int `json:"score"`
+}
+
+// Flag marks a player's behaviour in a match as suspicious
+type Flag struct {
+	PlayerID string `json:"player_id"`
+	Kind string `json:"kind"`
+	Detail string `json:"detail"`
+	At time.Time `json:"at"`
+}
+
+// MatchHistory keeps running matches and the most recent finished ones.
+// Rooms write to it from their own goroutine, so it has its own lock and
+// never calls back into rooms or the server.
+type MatchHistory struct {
+	mu sync.Mutex
+	size int
+	records []*MatchRecord		// oldest first, running matches included
+}
+
+func NewMatchHistory(size int) *MatchHistory {
+	return &MatchHistory{size: size}
+}
+
+func (h *MatchHistory) start(r *Room) *MatchRecord {
+	rec := &MatchRecord{
+		ID: uuid.NewString(),
+		RoomID: r.ID,
+		Mode: r.GameState.Mode,
+		StartedAt: r.GameState.StartedAt,
+	}
+	for _, p := range r.Players {
+		rec.Players = append(rec.Players, MatchPlayer{ID: p.ID, Name: p.Name, Number: p.Number, Team: p.Team})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, rec)
+	h.trim()
+	return rec
+}
+
+func (h *MatchHistory) flag(rec *MatchRecord, f Flag) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rec.Flags = append(rec.Flags, f)
+}
+
+// chat appends line to rec, keeping the last max lines
+func (h *MatchHistory) chat(rec *MatchRecord, line ChatLine, max int) {
+	if max <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rec.Chat = append(rec.Chat, line)
+	if len(rec.Chat) > max {
+		rec.Chat = append([]ChatLine(nil), rec.Chat[len(rec.Chat)-max:]...)
+	}
+}
+
+func (h *MatchHistory) finish(rec *MatchRecord, g *GameState, abandoned bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rec.EndedAt = time.Now()
+	rec.Abandoned = abandoned
+	for i := range rec.Players {
+		rec.Players[i].Score = g.Score[rec.Players[i].ID]
+	}
+	if !abandoned {
+		rec.Winner = g.Winner
+		rec.WinningTeam = g.WinningTeam
+	}
+	h.trim()
+}
+
+// trim drops the oldest finished records beyond size, expects h.mu to be held
+func (h *MatchHistory) trim() {
+	finished := 0
+	for _, rec := range h.records {
+		if !rec.EndedAt.IsZero() {
+			finished++
+		}
+	}
+
+	kept := h.records[:0]
+	for _, rec := range h.records {
+		if !rec.EndedAt.IsZero() && finished > h.size {
+			finished--
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	h.records = kept
+}
+
+// Matches returns copies of the records, newest first. With flaggedOnly only
+// matches with at least one flag are returned.
+func (h *MatchHistory) Matches(flaggedOnly bool) []MatchRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]MatchRecord, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0; i-- {
+		rec := *h.records[i]
+		if flaggedOnly && len(rec.Flags) == 0 {
+			continue
+		}
+		rec.Players = append([]MatchPlayer(nil), rec.Players...)
+		rec.Flags = append([]Flag(nil), rec.Flags...)
+		rec.Chat = append([]ChatLine(nil), rec.Chat...)
+		out = append(out, rec)
+	}
+	return out
+}
+
+// Matches lists recent matches for operators, see MatchHistory.Matches
+func (s *Server) Matches(flaggedOnly bool) []MatchRecord {
+	return s.history.Matches(flaggedOnly)
+}
@@ -0,0 +1,31 @@
+package server
+
+import "github.com/Gexff/word-search-1v1-go-websocket-server/internal/metrics"
+
+var (
+	connectedPlayers = metrics.NewGauge("wordsearch_connected_players", "Players with an open connection.")
+	activeRooms = metrics.NewGauge("wordsearch_active_rooms", "Rooms with at least one player.")
+	gamesInProgress = metrics.NewGauge("wordsearch_games_in_progress", "Games currently being played.")
+
+	gamesStarted = metrics.NewCounterVec("wordsearch_games_started_total", "Games started by mode.", "mode")
+	gamesFinished = metrics.NewCounterVec("wordsearch_games_finished_total", "Games finished by reason.", "reason")
+	invalidSelections = metrics.NewCounter("wordsearch_invalid_selections_total", "Selections that didn't match an unclaimed word.")
+
+	sendOverflows = metrics.NewCounterVec("wordsearch_send_buffer_overflows_total", "Messages that didn't fit a player's send queue, by what happened to the player.", "action")
+	messagesOut = metrics.NewCounterVec("wordsearch_messages_out_total", "Messages written to players by type.", "type")
+
+	boardGeneration = metrics.NewHistogram("wordsearch_board_generation_seconds", "Time taken to generate a board.", metrics.LatencyBuckets)
+	boardAttempts = metrics.NewHistogram("wordsearch_board_placement_attempts", "Placement attempts needed for a whole board.",
+		[]float64{10, 25, 50, 100, 250, 500, 1000, 2500})
+	wordsUnplaced = metrics.NewCounter("wordsearch_words_unplaced_total", "Words that couldn't be placed on a board.")
+)
+
+// messageType reads the type of an outgoing message for the metrics
+func messageType(msg interface{}) string {
+	if m, ok := msg.(map[string]interface{}); ok {
+		if t, ok := m["type"].(string); ok {
+			return t
+		}
+	}
+	return "other"
+}
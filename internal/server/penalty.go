@@ -0,0 +1,35 @@
+package server
+
+// Wrong-guess penalties, a room option
+const (
+	PenaltyOff = "off"
+	PenaltyCooldown = "cooldown"	// misses block select_word for a moment
+	PenaltyPoints = "points"		// misses cost points
+)
+
+func validPenalty(penalty string) bool {
+	return penalty == PenaltyOff || penalty == PenaltyCooldown || penalty == PenaltyPoints
+}
+
+// wrongGuess applies the room's penalty for a single miss
+func (r *Room) wrongGuess(p *Player, stats *selectionStats) {
+	switch r.Settings.Penalty {
+	case PenaltyCooldown:
+		r.penalize(p, stats, r.cfg.WrongGuessCooldown.Duration, 0, "wrong_guess")
+	case PenaltyPoints:
+		r.penalize(p, stats, 0, r.cfg.WrongGuessPoints, "wrong_guess")
+	}
+}
+
+// deduct takes up to points from p's score, never below 0, and from the
+// team's in teams mode. Returns what was actually taken.
+func (g *GameState) deduct(p *Player, points int) int {
+	if points > g.Score[p.ID] {
+		points = g.Score[p.ID]
+	}
+	g.Score[p.ID] -= points
+	if g.Mode == ModeTeams {
+		g.TeamScore[p.Team] -= points
+	}
+	return points
+}
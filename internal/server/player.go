@@ -1,46 +1,178 @@
-package server
-
-import (
-	"sync"
-	"github.com/gorilla/websocket"
-)
-
-type Player struct {
-	ID string
-	Name string
-	Number int 		// 1 or 2
-
-	Conn *websocket.Conn
-	Send chan interface{}
-
-	Room *Room
-
-	mu sync.Mutex
-}
-
-// Write to Send channel, then Player goroutine will write to socket
-func (p *Player) WritePump() {
-	defer p.Conn.Close()
-
-	for msg := range p.Send {
-		if err := p.Conn.WriteJSON(msg); err != nil {
-			// Socket Error
-			break
-		}
-	}
-}
-
-func (p *Player) Disconnect() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.Send != nil {
-		close(p.Send)
-		p.Send = nil
-	}
-
-	if p.Conn != nil {
-		p.Conn.Close()
-		p.Conn = nil
-	}
-}
\ No newline at end of file
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrPlayerGone = errors.New("player disconnected")
+	ErrSendQueueFull = errors.New("send queue full")
+)
+
+type Player struct {
+	ID string
+	Name string
+	Number int 		// seat in the room, 1..n in join order
+	Team int 		// teams mode only, 0 = no team
+
+	Conn *websocket.Conn
+	IP string 		// remote address the socket came from
+	Verified bool	// ID from a signed token, set before the player is added
+
+	room *Room 		// owned by the room goroutine, read with CurrentRoom
+
+	// send is only ever read by WritePump and never closed, done is closed
+	// exactly once by Disconnect
+	send chan interface{}
+	done chan struct{}
+	closeOnce sync.Once
+
+	lastActive time.Time
+
+	mu sync.Mutex
+}
+
+func NewPlayer(id string, conn *websocket.Conn, queueSize int) *Player {
+	return &Player{
+		ID: id,
+		Conn: conn,
+		send: make(chan interface{}, queueSize),
+		done: make(chan struct{}),
+		lastActive: time.Now(),
+	}
+}
+
+func (p *Player) CurrentRoom() *Room {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.room
+}
+
+func (p *Player) SetRoom(r *Room) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.room = r
+}
+
+// Logger tags records with the player and the room they're in
+func (p *Player) Logger() *slog.Logger {
+	l := slog.With("player_id", p.ID)
+	if r := p.CurrentRoom(); r != nil {
+		l = l.With("room_id", r.ID)
+	}
+	return l
+}
+
+// Send queues msg for the write pump without blocking. A player whose queue
+// is full can't keep up and gets disconnected.
+func (p *Player) Send(msg interface{}) error {
+	select {
+	case <-p.done:
+		return ErrPlayerGone
+	default:
+	}
+
+	select {
+	case p.send <- msg:
+		return nil
+	default:
+		sendOverflows.Inc("disconnected")
+		p.Disconnect()
+		return ErrSendQueueFull
+	}
+}
+
+// TrySend is Send for messages that are fine to lose: on a full queue msg is
+// dropped and the player stays connected
+func (p *Player) TrySend(msg interface{}) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+
+	select {
+	case p.send <- msg:
+		return true
+	default:
+		sendOverflows.Inc("dropped")
+		return false
+	}
+}
+
+// SendLossy is TrySend that also gives up once the queue is half full, so
+// high-frequency updates never crowd out messages Send must deliver
+func (p *Player) SendLossy(msg interface{}) bool {
+	if len(p.send) >= cap(p.send)/2 {
+		sendOverflows.Inc("dropped")
+		return false
+	}
+	return p.TrySend(msg)
+}
+
+// Done is closed once the player has been disconnected
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
+
+// Write to Send channel, then Player goroutine will write to socket.
+// Also pings the peer so the read side can detect half-open connections.
+func (p *Player) WritePump(hb Heartbeat) {
+	ticker := time.NewTicker(hb.PingInterval)
+	defer func() {
+		ticker.Stop()
+		p.Conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-p.send:
+			p.Conn.SetWriteDeadline(time.Now().Add(hb.WriteWait))
+			if cf, ok := msg.(closeFrame); ok {
+				p.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(cf.code, cf.reason))
+				p.Disconnect()
+				return
+			}
+			if err := p.Conn.WriteJSON(msg); err != nil {
+				// Socket Error or too slow
+				p.Disconnect()
+				return
+			}
+			messagesOut.Inc(messageType(msg))
+		case <-ticker.C:
+			if err := p.ping(hb); err != nil {
+				p.Disconnect()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+type closeFrame struct {
+	code int
+	reason string
+}
+
+// Close sends a close frame after everything already queued, then disconnects
+func (p *Player) Close(code int, reason string) {
+	if p.Send(closeFrame{code, reason}) != nil {
+		p.Disconnect()
+	}
+}
+
+// Disconnect is safe to call any number of times from any goroutine.
+// Closing the connection also unblocks the read pump.
+func (p *Player) Disconnect() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.Conn.Close()
+	})
+}
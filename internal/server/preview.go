@@ -0,0 +1,100 @@
+package server
+
+import (
+	"time"
+)
+
+// preview throttles one player's selection_preview relays
+type preview struct {
+	sent time.Time
+	pending interface{}		// latest preview waiting for the interval to pass
+	timer *time.Timer
+}
+
+// Preview relays where p is dragging a selection to the rest of the room. At
+// most one preview per player goes out every preview interval, newer ones
+// replace the waiting one. Previews are lossy: invalid ones and those sent
+// while the room has them turned off are dropped without an error.
+func (r *Room) Preview(p *Player, start, end Coord) {
+	g := r.GameState
+	if !g.GameStarted || !r.Settings.Previews || !g.onBoard(start) || !g.onBoard(end) {
+		return
+	}
+
+	msg := map[string]interface{}{
+		"type": "selection_preview",
+		"payload": map[string]interface{}{
+			"player_number": p.Number,
+			"start": [2]int{start.Row, start.Col},
+			"end": [2]int{end.Row, end.Col},
+		},
+	}
+
+	pv := r.previews[p.ID]
+	if pv == nil {
+		pv = &preview{}
+		r.previews[p.ID] = pv
+	}
+	if pv.timer != nil {
+		pv.pending = msg
+		return
+	}
+
+	wait := r.cfg.PreviewInterval.Duration - time.Since(pv.sent)
+	if wait <= 0 {
+		r.relayPreview(p, pv, msg)
+		return
+	}
+
+	pv.pending = msg
+	var timer *time.Timer
+	timer = time.AfterFunc(wait, func() {
+		r.Do(func() { r.flushPreview(p, timer) })
+	})
+	pv.timer = timer
+}
+
+func (r *Room) flushPreview(p *Player, timer *time.Timer) {
+	pv := r.previews[p.ID]
+	if pv == nil || pv.timer != timer { // game over or a new game started
+		return
+	}
+	pv.timer = nil
+	if r.GameState.GameStarted {
+		r.relayPreview(p, pv, pv.pending)
+	}
+	pv.pending = nil
+}
+
+// relayPreview sends to everyone but p. There are no spectators yet, they
+// would get previews here as well.
+func (r *Room) relayPreview(p *Player, pv *preview, msg interface{}) {
+	pv.sent = time.Now()
+	for _, other := range r.Players {
+		if other != p {
+			other.SendLossy(msg)
+		}
+	}
+}
+
+// dropPreview forgets p's waiting preview, once p left or was reseated
+func (r *Room) dropPreview(p *Player) {
+	if pv := r.previews[p.ID]; pv != nil && pv.timer != nil {
+		pv.timer.Stop()
+	}
+	delete(r.previews, p.ID)
+}
+
+// resetPreviews drops waiting previews, between games
+func (r *Room) resetPreviews() {
+	for _, pv := range r.previews {
+		if pv.timer != nil {
+			pv.timer.Stop()
+		}
+	}
+	r.previews = make(map[string]*preview)
+}
+
+func (g *GameState) onBoard(c Coord) bool {
+	return c.Row >= 0 && c.Row < len(g.Board) && c.Col >= 0 && c.Col < len(g.Board)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"time"
+)
+
+// holdSeat keeps a dropped player seated for the reconnect grace period when
+// they're in a running game or a series between rounds. Expects s.mu to be
+// held. Reports whether the seat is held, otherwise the caller removes them.
+// Only players with a verified ID can reconnect as themselves, anyone else
+// leaves right away.
+func (s *Server) holdSeat(player *Player) bool {
+	grace := s.cfg.Rooms.ReconnectGrace.Duration
+	room := player.CurrentRoom()
+	if grace <= 0 || room == nil || s.closing || !player.Verified {
+		return false
+	}
+
+	held := false
+	if !room.Do(func() { held = room.holdSeat(player, grace) }) || !held {
+		return false
+	}
+
+	player.Logger().Info("holding seat for reconnect", "grace", grace)
+	s.held[player.ID] = player
+	time.AfterFunc(grace, func() { s.expireSeat(player) })
+	return true
+}
+
+// expireSeat removes a held player that didn't come back in time
+func (s *Server) expireSeat(player *Player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held[player.ID] != player { // reconnected
+		return
+	}
+	delete(s.held, player.ID)
+	player.Logger().Info("reconnect grace expired")
+	s.leaveRoom(player)
+}
+
+// reseat moves old's seat to the connection that took over its ID, expects
+// s.mu to be held
+func (s *Server) reseat(old, p *Player) {
+	room := old.CurrentRoom()
+	if room == nil {
+		return
+	}
+	room.Do(func() { room.reseat(old, p) })
+}
+
+func (r *Room) holdSeat(p *Player, grace time.Duration) bool {
+	if !r.GameState.GameStarted && !r.InIntermission() {
+		return false
+	}
+	if r.seatOf(p) < 0 {
+		return false
+	}
+
+	r.away[p.ID] = true
+	r.Broadcast(map[string]interface{}{
+		"type": "player_disconnected",
+		"payload": map[string]interface{}{
+			"player_number": p.Number,
+			"seconds": int(grace / time.Second),
+			"players": r.PlayerList(),
+		},
+	})
+	return true
+}
+
+// reseat puts p in old's place with the same number, team, score and claims,
+// which all hang off the player ID, and sends p the room as it is now
+func (r *Room) reseat(old, p *Player) {
+	i := r.seatOf(old)
+	if i < 0 {
+		return
+	}
+	r.Players[i] = p
+	p.Number = old.Number
+	p.Team = old.Team
+	p.Name = old.Name
+	p.SetRoom(r)
+	old.SetRoom(nil)
+	delete(r.away, p.ID)
+
+	r.dropPreview(p)
+
+	r.logger().Info("player reseated", "player_id", p.ID)
+	for _, other := range r.Players {
+		if other != p {
+			other.Send(map[string]interface{}{
+				"type": "player_reconnected",
+				"payload": map[string]interface{}{
+					"player_number": p.Number,
+					"players": r.PlayerList(),
+				},
+			})
+		}
+	}
+	p.Send(r.Snapshot(p))
+}
+
+func (r *Room) seatOf(p *Player) int {
+	for i, seated := range r.Players {
+		if seated == p {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+type rematchRequest struct {
+	from *Player
+	settings RoomSettings	// the room settings with the proposed changes
+	accepted map[string]bool	// playerID -> accepted, the requester included
+	timer *time.Timer
+}
+
+// RequestRematch offers the other players another game. wordCount and gridSize
+// propose new settings for it, 0 keeps the current ones.
+func (r *Room) RequestRematch(p *Player, wordCount, gridSize int) error {
+	if len(r.Players) < MinCapacity {
+		return errors.New("waiting for an opponent")
+	}
+	if r.GameState.GameStarted {
+		return errors.New("game already started")
+	}
+	if r.nextRound != nil {
+		return errors.New("next round is about to start")
+	}
+	if r.rematch != nil {
+		return errors.New("rematch already requested")
+	}
+	if r.Settings.Mode == ModeTeams && !r.teamsReady() {
+		return errors.New("everyone needs a team, on at least two teams")
+	}
+
+	settings := r.Settings
+	if wordCount != 0 {
+		settings.WordCount = wordCount
+	}
+	if gridSize != 0 {
+		settings.GridSize = gridSize
+	}
+	if err := r.checkSettings(settings); err != nil {
+		return err
+	}
+
+	req := &rematchRequest{
+		from: p,
+		settings: settings,
+		accepted: map[string]bool{p.ID: true},
+	}
+	req.timer = time.AfterFunc(r.cfg.RematchTimeout.Duration, func() {
+		r.Do(func() { r.expireRematch(req) })
+	})
+	r.rematch = req
+
+	r.Broadcast(map[string]interface{}{
+		"type": "rematch_requested",
+		"payload": map[string]interface{}{
+			"from": p.Number,
+			"expires_in": int(r.cfg.RematchTimeout.Duration / time.Second),
+			"options": settings,
+		},
+	})
+	return nil
+}
+
+// AcceptRematch records p's acceptance. Once every player agreed the proposed
+// settings are applied and the game starts right away.
+func (r *Room) AcceptRematch(p *Player) error {
+	req := r.rematch
+	if req == nil {
+		return errors.New("no rematch requested")
+	}
+	if req.accepted[p.ID] {
+		return errors.New("waiting for opponent to accept")
+	}
+	// teams may have changed since the request, same check as a ready start
+	if r.Settings.Mode == ModeTeams && !r.teamsReady() {
+		return errors.New("everyone needs a team, on at least two teams")
+	}
+	req.accepted[p.ID] = true
+
+	r.Broadcast(map[string]interface{}{
+		"type": "rematch_accepted",
+		"payload": map[string]interface{}{
+			"by": p.Number,
+			"options": req.settings,
+		},
+	})
+
+	for _, member := range r.Players {
+		if !req.accepted[member.ID] {
+			return nil
+		}
+	}
+	r.clearRematch()
+
+	r.Settings = req.settings
+	r.resetReady()
+
+	r.logger().Info("rematch accepted")
+
+	r.StartGame()
+	return nil
+}
+
+// DeclineRematch rejects the opponent's request, or withdraws our own
+func (r *Room) DeclineRematch(p *Player) error {
+	if r.rematch == nil {
+		return errors.New("no rematch requested")
+	}
+	r.clearRematch()
+
+	r.Broadcast(map[string]interface{}{
+		"type": "rematch_declined",
+		"payload": map[string]interface{}{
+			"by": p.Number,
+		},
+	})
+	return nil
+}
+
+func (r *Room) expireRematch(req *rematchRequest) {
+	if r.rematch != req { // already answered
+		return
+	}
+	r.rematch = nil
+
+	r.Broadcast(map[string]interface{}{
+		"type": "rematch_expired",
+	})
+}
+
+func (r *Room) clearRematch() {
+	if r.rematch != nil {
+		r.rematch.timer.Stop()
+		r.rematch = nil
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+)
+
+// rematchRoom is two players waiting in the lobby
+func rematchRoom(t *testing.T) (*Room, []*Player) {
+	r := testRoom(t)
+	players := testPlayers(2)
+	r.Do(func() {
+		for _, p := range players {
+			r.AddPlayer(p)
+		}
+	})
+	return r, players
+}
+
+func TestRematchAccept(t *testing.T) {
+	r, players := rematchRoom(t)
+	r.Do(func() {
+		if r.AcceptRematch(players[1]) == nil {
+			t.Errorf("accepted a rematch nobody asked for")
+		}
+		if err := r.RequestRematch(players[0], 6, 12); err != nil {
+			t.Errorf("request: %v", err)
+			return
+		}
+		if r.RequestRematch(players[1], 0, 0) == nil {
+			t.Errorf("second request accepted")
+		}
+		if r.AcceptRematch(players[0]) == nil {
+			t.Errorf("requester accepted their own rematch")
+		}
+
+		if err := r.AcceptRematch(players[1]); err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		if !r.GameState.GameStarted || r.rematch != nil {
+			t.Errorf("rematch didn't start")
+		}
+		if r.Settings.WordCount != 6 || r.Settings.GridSize != 12 {
+			t.Errorf("proposed settings not applied: %+v", r.Settings)
+		}
+		if r.RequestRematch(players[0], 0, 0) == nil {
+			t.Errorf("rematch requested mid-game")
+		}
+	})
+}
+
+func TestRematchExpire(t *testing.T) {
+	r, players := rematchRoom(t)
+	r.Do(func() {
+		if err := r.RequestRematch(players[0], 0, 0); err != nil {
+			t.Errorf("request: %v", err)
+			return
+		}
+		req := r.rematch
+		received(players[1], "")
+
+		r.expireRematch(req)
+		if r.rematch != nil || received(players[1], "rematch_expired") == nil {
+			t.Errorf("rematch still pending after expiry")
+		}
+		if r.AcceptRematch(players[1]) == nil {
+			t.Errorf("accepted an expired rematch")
+		}
+
+		// a timer firing after a new request must leave that one alone
+		r.RequestRematch(players[1], 0, 0)
+		r.expireRematch(req)
+		if r.rematch == nil {
+			t.Errorf("stale timer expired the new request")
+		}
+	})
+}
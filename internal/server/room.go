@@ -1,101 +1,299 @@
-package server
-
-import (
-	"sync"
-)
-
-type Room struct {
-	ID   string
-	JoinCode string
-
-	Player1 *Player
-	Player2 *Player
-
-	PlayerReady [2]bool
-	GameState *GameState
-
-	mu sync.Mutex
-}
-
-func (r *Room) Broadcast(msg interface{}) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.Player1 != nil {
-		safeSend(r.Player1, msg)
-	}
-	if r.Player2 != nil {
-		safeSend(r.Player2, msg)
-	}
-}
-
-func (r *Room) SendToPlayer(p *Player, msg interface{}) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	safeSend(p, msg)
-}
-
-func safeSend(p *Player, msg interface{}) {
-	select {
-	case p.Send <- msg:
-		// Sent succesfully
-	default:
-		// player's send buffer is full → treat as disconnected
-		go p.Disconnect()
-	}
-}
-
-func (r *Room) SetReady(p *Player, state bool) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.Player1.ID == p.ID {
-		r.PlayerReady[0] = state
-	} else if r.Player2.ID == p.ID {
-		r.PlayerReady[1] = state
-	} 
-}
-
-func (r *Room) CheckStartCondition() bool {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	return r.PlayerReady[0] && r.PlayerReady[1]
-}
-
-func (r *Room) RemovePlayer(player *Player){
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.Player1 != nil && r.Player1.ID == player.ID {
-		r.Player1 = r.Player2
-		if r.Player1 != nil {
-			r.Player1.Number = 1
-		}
-		r.Player2 = nil
-	} else if r.Player2 != nil && r.Player2.ID == player.ID {
-		r.Player2 = nil
-	}
-	r.PlayerReady[0] = false
-	r.PlayerReady[1] = false
-	r.GameState.GameStarted = false
-
-	if r.Player1 == nil && r.Player2 == nil {
-		return
-	}
-
-	msg := map[string]interface{}{
-		"type": "player_left",
-		"payload": map[string]interface{}{
-			"player1_name": r.Player1.Name,
-			"player1_ready": r.PlayerReady[0],
-			"player2_ready": r.PlayerReady[1],
-		},
-	}
-	safeSend(r.Player1, msg)
-}
-
-func (r *Room) IsEmpty() bool {
-	return r.Player1 == nil && r.Player2 == nil
-}
\ No newline at end of file
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+// Hard room capacity limits, the configured maximum may be lower
+const (
+	MinCapacity = 2
+	MaxCapacity = 8
+)
+
+// Room state is owned by a single goroutine (see actor.go). Apart from Do
+// and Close, the methods of Room and of its GameState must only be called
+// from inside a Do callback.
+type Room struct {
+	ID   string
+	JoinCode string
+
+	Players []*Player		// in join order
+	HostID string			// playerID allowed to change settings
+	Settings RoomSettings	// applied to the next game, see settings.go
+	Locked bool				// no new players while set
+	banned map[string]bool	// playerIDs kicked by the host
+
+	Ready map[string]bool	// playerID -> ready
+	away map[string]bool	// playerIDs whose seat is held for a reconnect
+	GameState *GameState
+
+	SeriesScore map[string]int	// playerID -> rounds won
+	Round int					// rounds finished in the current series
+	nextRound *time.Timer	// pending intermission between series rounds
+	rematch *rematchRequest
+	clock *time.Timer		// co-op countdown of the running game
+
+	selections map[string]*selectionStats	// playerID -> anti-cheat stats, per game
+	hintsUsed map[string]int	// playerID -> hints used, per game
+	previews map[string]*preview	// playerID -> selection_preview throttle
+	match *MatchRecord		// record of the running game
+	history *MatchHistory
+	chatLog *MatchRecord	// record of the last game started, chat is kept there
+	chatLines int
+	muted map[string]map[string]bool	// playerID -> playerIDs whose chat they hide
+	wordlists map[string][]string	// the server's, read only
+
+	lastActivity time.Time	// last player action, see Touch
+	finishedAt time.Time	// when the last game ended, zero until one has
+	expiryWarned bool
+
+	cfg config.Rooms
+	antiCheat config.AntiCheat
+
+	cmds chan func()
+	done chan struct{}
+}
+
+func newRoom(id, code string, cfg *config.Config, history *MatchHistory, wordlists map[string][]string) *Room {
+	r := &Room{
+		ID: id,
+		JoinCode: code,
+		cfg: cfg.Rooms,
+		antiCheat: cfg.AntiCheat,
+		history: history,
+		wordlists: wordlists,
+		chatLines: cfg.Chat.HistoryLines,
+		muted: make(map[string]map[string]bool),
+		selections: make(map[string]*selectionStats),
+		hintsUsed: make(map[string]int),
+		previews: make(map[string]*preview),
+		Settings: defaultSettings(cfg.Rooms),
+		Ready: make(map[string]bool),
+		away: make(map[string]bool),
+		banned: make(map[string]bool),
+		GameState: &GameState{},
+		lastActivity: time.Now(),
+		SeriesScore: make(map[string]int),
+		cmds: make(chan func()),
+		done: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// logger tags records with the room, so one match's timeline can be filtered
+func (r *Room) logger() *slog.Logger {
+	return slog.With("room_id", r.ID)
+}
+
+// Broadcast never blocks the room, a member that can't keep up is
+// disconnected by Player.Send
+func (r *Room) Broadcast(msg interface{}) {
+	for _, p := range r.Players {
+		p.Send(msg)
+	}
+}
+
+// AddPlayer seats p in the next free slot
+func (r *Room) AddPlayer(p *Player) error {
+	if r.Locked {
+		return errors.New("room is locked")
+	}
+	if r.banned[p.ID] {
+		return errors.New("removed from this room by the host")
+	}
+	if r.GameState.GameStarted || r.InIntermission() {
+		return errors.New("game in progress")
+	}
+	if len(r.Players) >= r.Settings.MaxPlayers {
+		return errors.New("room is full")
+	}
+	if _, ok := r.Ready[p.ID]; ok {
+		return errors.New("already in this room from another session")
+	}
+
+	r.Players = append(r.Players, p)
+	r.Ready[p.ID] = false
+	r.Touch()
+	p.SetRoom(r)
+	p.Number = len(r.Players)
+	if r.HostID == "" {
+		r.HostID = p.ID
+	}
+	return nil
+}
+
+// Host returns the player allowed to change the room settings
+func (r *Room) Host() *Player {
+	return r.playerByID(r.HostID)
+}
+
+// PlayerList describes every member for player_joined/player_left style payloads
+func (r *Room) PlayerList() []map[string]interface{} {
+	players := make([]map[string]interface{}, 0, len(r.Players))
+	for _, p := range r.Players {
+		players = append(players, map[string]interface{}{
+			"number": p.Number,
+			"name": p.Name,
+			"ready": r.Ready[p.ID],
+			"team": p.Team,
+			"host": p.ID == r.HostID,
+			"away": r.away[p.ID],
+		})
+	}
+	return players
+}
+
+func (r *Room) playerByID(id string) *Player {
+	for _, p := range r.Players {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// byNumber re-keys a playerID map by player number, which is what clients know
+func (r *Room) byNumber(scores map[string]int) map[int]int {
+	out := make(map[int]int, len(r.Players))
+	for _, p := range r.Players {
+		out[p.Number] = scores[p.ID]
+	}
+	return out
+}
+
+// StartGame deals a new board to everyone in the room
+func (r *Room) StartGame() {
+	r.GameState.configure(r.Settings, r.wordlists[r.Settings.WordList])
+	r.hintsUsed = make(map[string]int)
+	r.resetPreviews()
+	r.Broadcast(r.GameState.StartGame(r.Players))
+	r.finishedAt = time.Time{}
+	r.startMatch()
+	r.armClock()
+}
+
+func (r *Room) SetReady(p *Player, state bool) {
+	r.clearRematch() // readying up replaces any pending rematch request
+
+	if _, ok := r.Ready[p.ID]; ok {
+		r.Ready[p.ID] = state
+	}
+}
+
+func (r *Room) CheckStartCondition() bool {
+	if len(r.Players) < MinCapacity {
+		return false
+	}
+	if r.Settings.Mode == ModeTeams && !r.teamsReady() {
+		return false
+	}
+	for _, p := range r.Players {
+		if !r.Ready[p.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Room) resetReady() {
+	for id := range r.Ready {
+		r.Ready[id] = false
+	}
+}
+
+func (r *Room) RemovePlayer(player *Player){
+	found := false
+	for i, p := range r.Players {
+		if p == player {
+			r.Players = append(r.Players[:i], r.Players[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	delete(r.Ready, player.ID)
+	delete(r.away, player.ID)
+	delete(r.muted, player.ID)
+	r.dropPreview(player)
+	player.SetRoom(nil)
+
+	// close the gap so numbers stay 1..n
+	for i, p := range r.Players {
+		p.Number = i + 1
+	}
+
+	r.resetReady()
+	playOn := r.canPlayOn()
+	if !playOn {
+		r.abandonGame()
+		r.stopClock()
+		r.resetSeries()
+	}
+	r.clearRematch()
+
+	if len(r.Players) == 0 {
+		r.HostID = ""
+		return
+	}
+
+	if player.ID == r.HostID { // the longest-seated player takes over
+		r.setHost(r.Players[0], "host_left")
+	}
+
+	r.Broadcast(map[string]interface{}{
+		"type": "player_left",
+		"payload": map[string]interface{}{
+			"name": player.Name,
+			"players": r.PlayerList(),
+		},
+	})
+
+	// without the leaver someone may already be out of reach
+	if playOn && r.GameState.GameStarted {
+		if msg, over := r.GameState.CheckForWinner(r); over {
+			r.Broadcast(msg)
+			r.EndRound()
+		}
+	}
+}
+
+// canPlayOn reports whether the players left can finish the game and series,
+// scores are kept by player ID so the leaver's claims still count
+func (r *Room) canPlayOn() bool {
+	if len(r.Players) < MinCapacity {
+		return false
+	}
+	return r.GameState.Mode != ModeTeams || r.teamsReady()
+}
+
+// abandonGame ends a running game nobody can finish, so the clients left
+// don't sit on a dead board
+func (r *Room) abandonGame() {
+	g := r.GameState
+	if !g.GameStarted {
+		return
+	}
+	r.finishMatch(true)
+	g.GameStarted = false
+	g.Winner = ""
+	g.WinningTeam = 0
+
+	r.Broadcast(map[string]interface{}{
+		"type": "game_over",
+		"payload": map[string]interface{}{
+			"abandoned": true,
+			"score": r.byNumber(g.Score),
+			"unclaimed_words": g.getUnclaimedWordCoords(),
+		},
+	})
+}
+
+func (r *Room) IsEmpty() bool {
+	return len(r.Players) == 0
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"testing"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+var testWords = []string{
+	"apple", "bread", "chair", "dance", "eagle", "flute", "grape", "house",
+	"igloo", "juice", "kite", "lemon", "mango", "night", "ocean", "piano",
+}
+
+func testRoom(t *testing.T) *Room {
+	cfg := config.Default()
+	r := newRoom("room", "ABCDEF", cfg, NewMatchHistory(10), map[string][]string{
+		"default": testWords,
+		"tiny": {"cat", "dog"},
+	})
+	t.Cleanup(r.Close)
+	return r
+}
+
+// received drains p's queue and returns the payload of the last msgType,
+// empty when it had none and nil when none came
+func received(p *Player, msgType string) map[string]interface{} {
+	var last map[string]interface{}
+	for {
+		select {
+		case msg := <-p.send:
+			if m, ok := msg.(map[string]interface{}); ok && m["type"] == msgType {
+				last, ok = m["payload"].(map[string]interface{})
+				if !ok {
+					last = map[string]interface{}{}
+				}
+			}
+		default:
+			return last
+		}
+	}
+}
+
+func TestRemovePlayerMidGame(t *testing.T) {
+	tests := []struct {
+		name string
+		players int
+		playOn bool
+	}{
+		{"last opponent leaves", 2, false},
+		{"two players left", 3, true},
+	}
+	for _, tt := range tests {
+		r := testRoom(t)
+		players := testPlayers(tt.players)
+		r.Do(func() {
+			r.Settings.MaxPlayers = tt.players
+			for _, p := range players {
+				if err := r.AddPlayer(p); err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					return
+				}
+			}
+			r.StartGame()
+			r.GameState.claim(players[1])
+			r.RemovePlayer(players[1])
+
+			stay := players[0]
+			if r.GameState.GameStarted != tt.playOn {
+				t.Errorf("%s: GameStarted = %v", tt.name, r.GameState.GameStarted)
+			}
+			over := received(stay, "game_over")
+			if tt.playOn {
+				if over != nil {
+					t.Errorf("%s: game_over %v", tt.name, over)
+				}
+				if r.GameState.Score[players[1].ID] != 1 {
+					t.Errorf("%s: the leaver's claim was dropped", tt.name)
+				}
+				return
+			}
+			if over == nil || over["abandoned"] != true {
+				t.Errorf("%s: game_over = %v, want abandoned", tt.name, over)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"time"
+)
+
+func validSeriesLength(n int) bool {
+	return n == 1 || n == 3 || n == 5 || n == 7
+}
+
+// InIntermission reports whether the room is waiting to start the next round of a series
+func (r *Room) InIntermission() bool {
+	return r.nextRound != nil
+}
+
+// EndRound records the result of the game that just finished. Single games
+// only reset readiness, a series either schedules the next round or
+// announces the series winner. Co-op games have no winner and are never
+// played as a series.
+func (r *Room) EndRound() {
+	r.finishMatch(false)
+	r.finishedAt = time.Now()
+	r.stopClock()
+	r.resetReady()
+
+	if r.Settings.SeriesLength <= 1 || r.GameState.Mode == ModeCoop {
+		return
+	}
+
+	r.Round++
+
+	// nobody scores on a draw
+	if winners := r.roundWinners(); len(winners) > 0 {
+		for _, p := range winners {
+			r.SeriesScore[p.ID]++
+		}
+
+		if r.SeriesScore[winners[0].ID] >= r.Settings.SeriesLength/2 + 1 {
+			payload := map[string]interface{}{
+				"series_score": r.byNumber(r.SeriesScore),
+				"series_length": r.Settings.SeriesLength,
+			}
+			if team := r.GameState.WinningTeam; team != 0 {
+				payload["winner_team"] = team
+			} else {
+				payload["winner"] = winners[0].Number
+			}
+
+			r.Broadcast(map[string]interface{}{
+				"type": "series_over",
+				"payload": payload,
+			})
+			r.resetSeries()
+			return
+		}
+	}
+
+	r.Broadcast(map[string]interface{}{
+		"type": "next_round",
+		"payload": map[string]interface{}{
+			"round": r.Round + 1,
+			"series_score": r.byNumber(r.SeriesScore),
+			"series_length": r.Settings.SeriesLength,
+			"starts_in": int(r.cfg.SeriesIntermission.Duration / time.Second),
+		},
+	})
+
+	var timer *time.Timer
+	timer = time.AfterFunc(r.cfg.SeriesIntermission.Duration, func() {
+		r.Do(func() { r.startNextRound(timer) })
+	})
+	r.nextRound = timer
+}
+
+func (r *Room) startNextRound(timer *time.Timer) {
+	if r.nextRound != timer { // cancelled or replaced
+		return
+	}
+	r.nextRound = nil
+
+	if len(r.Players) < MinCapacity {
+		return
+	}
+
+	r.logger().Info("starting next series round", "round", r.Round + 1)
+	r.StartGame()
+}
+
+// roundWinners lists everyone credited with the last game, the whole team in
+// teams mode
+func (r *Room) roundWinners() []*Player {
+	var winners []*Player
+	if team := r.GameState.WinningTeam; team != 0 {
+		for _, p := range r.Players {
+			if p.Team == team {
+				winners = append(winners, p)
+			}
+		}
+	} else if p := r.playerByID(r.GameState.Winner); p != nil {
+		winners = append(winners, p)
+	}
+	return winners
+}
+
+func (r *Room) seriesInProgress() bool {
+	return r.nextRound != nil || r.Round > 0
+}
+
+func (r *Room) resetSeries() {
+	if r.nextRound != nil {
+		r.nextRound.Stop()
+		r.nextRound = nil
+	}
+	r.SeriesScore = make(map[string]int)
+	r.Round = 0
+}
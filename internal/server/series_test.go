@@ -0,0 +1,89 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestEndRoundSeries(t *testing.T) {
+	tests := []struct {
+		name string
+		length int
+		winners []int	// player index per round, -1 = draw
+		over int		// round the series ends in, 0 = still running
+		winner int		// player number
+	}{
+		{"single game", 1, []int{0}, 0, 0},
+		{"clinched in two", 3, []int{1, 1}, 2, 2},
+		{"goes to three", 3, []int{0, 1, 0}, 3, 1},
+		{"draws don't count", 3, []int{0, -1, -1, 0}, 4, 1},
+		{"best of five, not yet", 5, []int{0, 0, 1, 1}, 0, 0},
+	}
+	for _, tt := range tests {
+		r := testRoom(t)
+		players := testPlayers(2)
+		r.Do(func() {
+			r.Settings.SeriesLength = tt.length
+			for _, p := range players {
+				if err := r.AddPlayer(p); err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					return
+				}
+			}
+
+			for round, i := range tt.winners {
+				if r.nextRound != nil { // skip the intermission
+					r.nextRound.Stop()
+					r.nextRound = nil
+				}
+				r.StartGame()
+				r.GameState.GameStarted = false
+				r.GameState.Winner = ""
+				if i >= 0 {
+					r.GameState.Winner = players[i].ID
+				}
+				r.EndRound()
+
+				over := received(players[0], "series_over")
+				if over == nil {
+					continue
+				}
+				if round+1 != tt.over || over["winner"] != tt.winner {
+					t.Errorf("%s: series over in round %d with %v", tt.name, round+1, over)
+				}
+				if r.Round != 0 || r.InIntermission() {
+					t.Errorf("%s: series not reset", tt.name)
+				}
+				return
+			}
+			if tt.over != 0 {
+				t.Errorf("%s: no series_over", tt.name)
+			}
+			if tt.length > 1 && !r.InIntermission() {
+				t.Errorf("%s: no next round scheduled", tt.name)
+			}
+		})
+	}
+}
+
+func TestJoinDuringIntermission(t *testing.T) {
+	r := testRoom(t)
+	players := testPlayers(3)
+	r.Do(func() {
+		r.Settings.SeriesLength = 3
+		r.Settings.MaxPlayers = 3
+		r.AddPlayer(players[0])
+		r.AddPlayer(players[1])
+		r.StartGame()
+		r.GameState.GameStarted = false
+		r.GameState.Winner = players[0].ID
+		r.EndRound()
+
+		if !r.InIntermission() {
+			t.Errorf("no intermission after the first round")
+			return
+		}
+		if r.AddPlayer(players[2]) == nil {
+			t.Errorf("joined between rounds")
+		}
+	})
+}
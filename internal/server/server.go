@@ -1,143 +1,202 @@
-package server
-
-import (
-	"sync"
-	"time"
-	"math/rand"
-	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/game"
-	"errors"
-	"log"
-)
-
-type Server struct {
-	mu sync.Mutex
-	players map[string]*Player
-	rooms map[string]*Room	// roomID -> room
-	codes map[string]*Room // JoinCode-> room
-	wordlist []string
-}
-
-const wordlistPath = "config/words.txt"
-
-func New() *Server {
-	rand.Seed(time.Now().UnixNano())
-	
-	return &Server {
-		players: make(map[string]*Player),
-		rooms: make(map[string]*Room),
-		codes: make(map[string]*Room),
-		wordlist: game.ReadWords(wordlistPath),
-	}
-}
-
-func (s *Server) AddPlayer(p *Player) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.players[p.ID] = p
-}
-
-func (s *Server) RemovePlayer(player *Player) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	delete(s.players, player.ID)
-
-	if room := player.Room; room != nil {
-		room.RemovePlayer(player)
-
-		if room.IsEmpty() {
-			log.Println("deleting room: ", room)
-			delete(s.rooms, room.ID)
-			delete(s.codes, room.JoinCode)
-		}
-	}
-}
-
-func (s *Server) RemovePlayerFromRoom(player *Player) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if room := player.Room; room != nil {
-		room.RemovePlayer(player)
-
-		if room.IsEmpty() {
-			log.Println("deleting room: ", room)
-			delete(s.rooms, room.ID)
-			delete(s.codes, room.JoinCode)
-		}
-	}
-
-	player.Room = nil
-}
-
-func (s *Server) CreateRoom(owner *Player, roomID string) (*Room, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if (owner.Room != nil) {
-		return nil, errors.New("player already in room")
-		log.Println("failed to create room, player already in room. player: ", owner.ID)
-	}
-
-	var code string
-    for {
-        code = generateCode()
-        if _, exists := s.codes[code]; !exists {
-            break
-        }
-    }
-
-	room := &Room{
-		ID: roomID,
-		JoinCode: code,
-		Player1: owner,
-		PlayerReady: [2]bool{false, false},
-		GameState: &GameState{
-			wordlist: s.wordlist,
-			WordCount: 7,	// Default settings
-			GridSize: 12,
-		},
-	}
-
-	s.rooms[room.ID] = room
-	s.codes[room.JoinCode] = room
-	
-	owner.Room = room
-	owner.Number = 1
-
-	log.Println("room created: ", room.ID, " JoinCode: ", room.JoinCode, " owner: ", owner.ID)
-
-	return room, nil
-}
-
-func (s *Server) JoinRoomByCode(p *Player, code string) (*Room, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	room, exists := s.codes[code]
-	if !exists {
-		log.Println("failed to join room. requesting player: ", p.ID, " code: ", code)
-		return nil, errors.New("invalid room code")
-	}
-
-	if room.Player2 != nil {
-		return nil, errors.New("room is full")
-	}
-
-	room.Player2 = p
-	p.Room = room
-	p.Number = 2
-	return room, nil
-}
-
-const codeLength = 6
-const letters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
-
-func generateCode() string {
-	b := make([]byte, codeLength)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
+package server
+
+import (
+	"sync"
+	"time"
+	"math/rand"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/game"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+)
+
+// ErrInvalidCode is returned for join codes that don't match a room
+var ErrInvalidCode = errors.New("invalid room code")
+
+type Server struct {
+	mu sync.Mutex
+	players map[string]*Player
+	rooms map[string]*Room	// roomID -> room
+	codes map[string]*Room // JoinCode-> room
+	wordlists map[string][]string	// name -> words
+	held map[string]*Player		// playerID -> dropped connection whose seat is held, see reconnect.go
+	cfg *config.Config
+	closing bool
+
+	history *MatchHistory
+}
+
+func New(cfg *config.Config) (*Server, error) {
+	rand.Seed(time.Now().UnixNano())
+
+	wordlists := make(map[string][]string, len(cfg.WordLists))
+	for name, path := range cfg.WordLists {
+		words, err := game.ReadWords(path)
+		if err != nil {
+			return nil, fmt.Errorf("word list %q: %v", name, err)
+		}
+		if len(words) < cfg.Rooms.MaxWordCount {
+			return nil, fmt.Errorf("word list %q has %d words, rooms.max_word_count needs %d", name, len(words), cfg.Rooms.MaxWordCount)
+		}
+		if err := checkBoard(words, cfg.Rooms.DefaultWordCount, cfg.Rooms.DefaultGridSize, cfg.Rooms.MaxGridFill); err != nil {
+			return nil, fmt.Errorf("word list %q with the default room settings: %v", name, err)
+		}
+		wordlists[name] = words
+	}
+
+	return &Server {
+		players: make(map[string]*Player),
+		rooms: make(map[string]*Room),
+		codes: make(map[string]*Room),
+		held: make(map[string]*Player),
+		wordlists: wordlists,
+		cfg: cfg,
+		history: NewMatchHistory(cfg.AntiCheat.MatchHistory),
+	}, nil
+}
+
+// AddPlayer registers a connection. An authenticated player connecting again
+// takes over their ID and their seat, the older connection is closed.
+func (s *Server) AddPlayer(p *Player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var old *Player
+	if current, ok := s.players[p.ID]; ok {
+		p.Logger().Info("player reconnected, closing previous connection")
+		current.Close(websocket.ClosePolicyViolation, "connected from another session")
+		old = current
+	} else {
+		connectedPlayers.Inc()
+		old = s.held[p.ID]
+		delete(s.held, p.ID)
+	}
+	s.players[p.ID] = p
+
+	if old != nil {
+		s.reseat(old, p)
+	}
+}
+
+func (s *Server) RemovePlayer(player *Player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.players[player.ID] == player {
+		delete(s.players, player.ID)
+		connectedPlayers.Dec()
+	}
+	if s.holdSeat(player) {
+		return
+	}
+	s.leaveRoom(player)
+}
+
+func (s *Server) RemovePlayerFromRoom(player *Player) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leaveRoom(player)
+}
+
+// leaveRoom expects s.mu to be held. Rooms never call back into the server,
+// so waiting on the room goroutine here can't deadlock.
+func (s *Server) leaveRoom(player *Player) {
+	room := player.CurrentRoom()
+	if room == nil {
+		return
+	}
+
+	empty := false
+	room.Do(func() {
+		room.RemovePlayer(player)
+		empty = room.IsEmpty()
+	})
+
+	if empty {
+		room.logger().Info("deleting room")
+		s.removeRoom(room)
+	}
+}
+
+// removeRoom forgets the room and releases its join code, expects s.mu to be held
+func (s *Server) removeRoom(room *Room) {
+	delete(s.rooms, room.ID)
+	delete(s.codes, room.JoinCode)
+	room.Close()
+	activeRooms.Dec()
+}
+
+func (s *Server) CreateRoom(owner *Player, roomID string) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return nil, errors.New("server is shutting down")
+	}
+
+	if (owner.CurrentRoom() != nil) {
+		owner.Logger().Debug("failed to create room, player already in room")
+		return nil, errors.New("player already in room")
+	}
+
+	code := s.newCode()
+
+	room := newRoom(roomID, code, s.cfg, s.history, s.wordlists)
+	room.Do(func() { room.AddPlayer(owner) })
+
+	s.rooms[room.ID] = room
+	s.codes[room.JoinCode] = room
+	activeRooms.Inc()
+
+	room.logger().Info("room created", "join_code", room.JoinCode, "player_id", owner.ID)
+
+	return room, nil
+}
+
+func (s *Server) JoinRoomByCode(p *Player, code string) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return nil, errors.New("server is shutting down")
+	}
+
+	room, exists := s.codes[code]
+	if !exists {
+		p.Logger().Debug("failed to join room", "code", code)
+		return nil, ErrInvalidCode
+	}
+
+	if p.CurrentRoom() != nil {
+		return nil, errors.New("player already in room")
+	}
+
+	var err error
+	room.Do(func() { err = room.AddPlayer(p) })
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+const letters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newCode picks a join code no room is using, expects s.mu to be held
+func (s *Server) newCode() string {
+	for {
+		code := generateCode(s.cfg.Rooms.CodeLength)
+		if _, exists := s.codes[code]; !exists {
+			return code
+		}
+	}
+}
+
+func generateCode(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
 }
\ No newline at end of file
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+// Which ways words may run on the board, a room option
+const (
+	DirectionsAll = "all"			// any of the 8 directions, backwards too
+	DirectionsForward = "forward"	// left to right and top to bottom, diagonals included
+	DirectionsStraight = "straight"	// left to right and top to bottom only
+)
+
+var directions = map[string][][2]int{
+	DirectionsAll: {{0, 1}, {1, 0}, {1, 1}, {-1, 1}, {0, -1}, {-1, 0}, {-1, -1}, {1, -1}},
+	DirectionsForward: {{0, 1}, {1, 0}, {1, 1}, {-1, 1}},
+	DirectionsStraight: {{0, 1}, {1, 0}},
+}
+
+// RoomSettings holds every option the host can change between games. It's
+// sent as "options" wherever a message describes the room.
+type RoomSettings struct {
+	GridSize int `json:"grid_size"`
+	WordCount int `json:"word_count"`
+	WordList string `json:"word_list"`		// name from the word_lists config
+	Directions string `json:"directions"`
+	TimeLimit int `json:"time_limit"`		// seconds, co-op only
+	Mode string `json:"mode"`
+	SeriesLength int `json:"series_length"`	// best of N, 1 = single games
+	MaxPlayers int `json:"max_players"`
+	Penalty string `json:"penalty"`			// what a wrong guess costs, see penalty.go
+	Hints int `json:"hints"`				// per player and game, 0 = off
+	HintCost int `json:"hint_cost"`			// points per hint
+	Previews bool `json:"previews"`			// relay selection_preview, off for competitive play
+}
+
+func defaultSettings(cfg config.Rooms) RoomSettings {
+	return RoomSettings{
+		GridSize: cfg.DefaultGridSize,
+		WordCount: cfg.DefaultWordCount,
+		WordList: "default",
+		Directions: DirectionsAll,
+		TimeLimit: cfg.DefaultTimeLimit,
+		Mode: ModeLockout,
+		SeriesLength: 1,
+		MaxPlayers: cfg.DefaultMaxPlayers,
+		Penalty: PenaltyOff,
+		Hints: cfg.DefaultHints,
+		Previews: true,
+	}
+}
+
+// SettingsUpdate is a partial update_settings request, nil fields stay as they are
+type SettingsUpdate struct {
+	GridSize *int `json:"grid_size"`
+	WordCount *int `json:"word_count"`
+	WordList *string `json:"word_list"`
+	Directions *string `json:"directions"`
+	TimeLimit *int `json:"time_limit"`
+	Mode *string `json:"mode"`
+	SeriesLength *int `json:"series_length"`
+	MaxPlayers *int `json:"max_players"`
+	Penalty *string `json:"penalty"`
+	Hints *int `json:"hints"`
+	HintCost *int `json:"hint_cost"`
+	Previews *bool `json:"previews"`
+}
+
+func (u SettingsUpdate) apply(s RoomSettings) RoomSettings {
+	if u.GridSize != nil {
+		s.GridSize = *u.GridSize
+	}
+	if u.WordCount != nil {
+		s.WordCount = *u.WordCount
+	}
+	if u.WordList != nil {
+		s.WordList = *u.WordList
+	}
+	if u.Directions != nil {
+		s.Directions = *u.Directions
+	}
+	if u.TimeLimit != nil {
+		s.TimeLimit = *u.TimeLimit
+	}
+	if u.Mode != nil {
+		s.Mode = *u.Mode
+	}
+	if u.SeriesLength != nil {
+		s.SeriesLength = *u.SeriesLength
+	}
+	if u.MaxPlayers != nil {
+		s.MaxPlayers = *u.MaxPlayers
+	}
+	if u.Penalty != nil {
+		s.Penalty = *u.Penalty
+	}
+	if u.Hints != nil {
+		s.Hints = *u.Hints
+	}
+	if u.HintCost != nil {
+		s.HintCost = *u.HintCost
+	}
+	if u.Previews != nil {
+		s.Previews = *u.Previews
+	}
+	return s
+}
+
+// UpdateSettings applies a host's changes between games. Either every field
+// is valid and applied or nothing changes. Everyone but the host has to
+// confirm ready again.
+func (r *Room) UpdateSettings(p *Player, u SettingsUpdate) error {
+	if !r.IsHost(p) {
+		return errors.New("only the host can modify game settings")
+	}
+	if r.GameState.GameStarted {
+		return errors.New("game already started")
+	}
+	if r.InIntermission() {
+		return errors.New("next round is about to start")
+	}
+
+	s := u.apply(r.Settings)
+	if s == r.Settings {
+		r.broadcastSettings()
+		return nil
+	}
+
+	if err := r.checkSettings(s); err != nil {
+		return err
+	}
+	if s.MaxPlayers < len(r.Players) {
+		return errors.New("room size smaller than current player count")
+	}
+	if s.SeriesLength != r.Settings.SeriesLength && r.seriesInProgress() {
+		return errors.New("series already in progress")
+	}
+
+	r.Settings = s
+	r.clearRematch()
+	for id := range r.Ready {
+		if id != p.ID {
+			r.Ready[id] = false
+		}
+	}
+
+	r.broadcastSettings()
+	return nil
+}
+
+// checkSettings validates s against the configured limits and word lists
+func (r *Room) checkSettings(s RoomSettings) error {
+	cfg := r.cfg
+	if s.GridSize < cfg.MinGridSize || s.GridSize > cfg.MaxGridSize {
+		return fmt.Errorf("invalid grid size. must be between %d and %d.", cfg.MinGridSize, cfg.MaxGridSize)
+	}
+	if s.WordCount < cfg.MinWordCount || s.WordCount > cfg.MaxWordCount {
+		return fmt.Errorf("invalid word count. must be between %d and %d.", cfg.MinWordCount, cfg.MaxWordCount)
+	}
+	if _, ok := r.wordlists[s.WordList]; !ok {
+		return errors.New("unknown word list")
+	}
+	if _, ok := directions[s.Directions]; !ok {
+		return errors.New("invalid directions. must be all, forward or straight.")
+	}
+	if s.TimeLimit < cfg.MinTimeLimit || s.TimeLimit > cfg.MaxTimeLimit {
+		return fmt.Errorf("invalid time limit. must be between %d and %d seconds.", cfg.MinTimeLimit, cfg.MaxTimeLimit)
+	}
+	if !ValidMode(s.Mode) {
+		return errors.New("unknown game mode")
+	}
+	if !validSeriesLength(s.SeriesLength) {
+		return errors.New("invalid series length. must be 1, 3, 5 or 7.")
+	}
+	if s.MaxPlayers < MinCapacity || s.MaxPlayers > cfg.MaxPlayers {
+		return fmt.Errorf("invalid room size. must be between %d and %d players.", MinCapacity, cfg.MaxPlayers)
+	}
+	if !validPenalty(s.Penalty) {
+		return errors.New("invalid penalty. must be off, cooldown or points.")
+	}
+	if s.Hints < 0 || s.Hints > cfg.MaxHints {
+		return fmt.Errorf("invalid hints. must be between 0 and %d.", cfg.MaxHints)
+	}
+	if s.HintCost < 0 || s.HintCost > cfg.MaxHintCost {
+		return fmt.Errorf("invalid hint cost. must be between 0 and %d points.", cfg.MaxHintCost)
+	}
+	return checkBoard(r.wordlists[s.WordList], s.WordCount, s.GridSize, cfg.MaxGridFill)
+}
+
+func (r *Room) broadcastSettings() {
+	r.Broadcast(map[string]interface{}{
+		"type": "game_settings",
+		"payload": map[string]interface{}{
+			"options": r.Settings,
+			"players": r.PlayerList(),
+		},
+	})
+}
+
+// configure copies the room settings into the game about to start, so
+// they can't change under a running game
+func (g *GameState) configure(s RoomSettings, words []string) {
+	g.WordCount = s.WordCount
+	g.GridSize = s.GridSize
+	g.Mode = s.Mode
+	g.TimeLimit = s.TimeLimit
+	g.wordlist = words
+	g.directions = directions[s.Directions]
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"testing"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+func TestSettingsUpdateApply(t *testing.T) {
+	s := defaultSettings(config.Default().Rooms)
+
+	if got := (SettingsUpdate{}).apply(s); got != s {
+		t.Errorf("empty update changed %+v to %+v", s, got)
+	}
+
+	size, mode, previews := 15, ModeTeams, false
+	got := SettingsUpdate{GridSize: &size, Mode: &mode, Previews: &previews}.apply(s)
+	want := s
+	want.GridSize, want.Mode, want.Previews = 15, ModeTeams, false
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckSettings(t *testing.T) {
+	r := testRoom(t)
+	valid := r.Settings
+	if err := r.checkSettings(valid); err != nil {
+		t.Fatalf("default settings rejected: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		change func(s *RoomSettings)
+	}{
+		{"grid too small", func(s *RoomSettings) { s.GridSize = r.cfg.MinGridSize - 1 }},
+		{"grid too large", func(s *RoomSettings) { s.GridSize = r.cfg.MaxGridSize + 1 }},
+		{"no words", func(s *RoomSettings) { s.WordCount = 0 }},
+		{"more words than the list has", func(s *RoomSettings) { s.WordCount = len(testWords) + 1 }},
+		{"unknown word list", func(s *RoomSettings) { s.WordList = "nope" }},
+		{"word list too short", func(s *RoomSettings) { s.WordList = "tiny" }},
+		{"directions", func(s *RoomSettings) { s.Directions = "diagonal" }},
+		{"time limit", func(s *RoomSettings) { s.TimeLimit = r.cfg.MaxTimeLimit + 1 }},
+		{"mode", func(s *RoomSettings) { s.Mode = "battle royale" }},
+		{"series length", func(s *RoomSettings) { s.SeriesLength = 2 }},
+		{"room size", func(s *RoomSettings) { s.MaxPlayers = MinCapacity - 1 }},
+		{"penalty", func(s *RoomSettings) { s.Penalty = "death" }},
+		{"hints", func(s *RoomSettings) { s.Hints = r.cfg.MaxHints + 1 }},
+		{"hint cost", func(s *RoomSettings) { s.HintCost = -1 }},
+	}
+	for _, tt := range tests {
+		s := valid
+		tt.change(&s)
+		if err := r.checkSettings(s); err == nil {
+			t.Errorf("%s: accepted %+v", tt.name, s)
+		}
+	}
+
+	s := valid
+	s.Mode, s.SeriesLength, s.Directions, s.Hints, s.HintCost = ModeTeams, 5, DirectionsStraight, 0, r.cfg.MaxHintCost
+	if err := r.checkSettings(s); err != nil {
+		t.Errorf("valid settings rejected: %v", err)
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	r := testRoom(t)
+	players := testPlayers(3)
+	host, guest := players[0], players[1]
+
+	// on the room goroutine, like every handler, so no t.Fatal in here
+	r.Do(func() {
+		r.AddPlayer(host)
+		r.AddPlayer(guest)
+		r.Ready[host.ID], r.Ready[guest.ID] = true, true
+
+		size, bad := 14, "nope"
+		if err := r.UpdateSettings(guest, SettingsUpdate{GridSize: &size}); err == nil {
+			t.Error("guest changed settings")
+		}
+
+		// all or nothing
+		if err := r.UpdateSettings(host, SettingsUpdate{GridSize: &size, WordList: &bad}); err == nil {
+			t.Error("unknown word list accepted")
+		}
+		if r.Settings.GridSize == size || !r.Ready[guest.ID] {
+			t.Error("rejected update applied")
+		}
+
+		if err := r.UpdateSettings(host, SettingsUpdate{GridSize: &size}); err != nil {
+			t.Errorf("UpdateSettings: %v", err)
+			return
+		}
+		if r.Settings.GridSize != size {
+			t.Error("grid size not applied")
+		}
+		if !r.Ready[host.ID] || r.Ready[guest.ID] {
+			t.Error("only the host should stay ready")
+		}
+
+		three, two := 3, 2
+		if err := r.UpdateSettings(host, SettingsUpdate{MaxPlayers: &three}); err != nil {
+			t.Errorf("UpdateSettings: %v", err)
+			return
+		}
+		if err := r.AddPlayer(players[2]); err != nil {
+			t.Errorf("AddPlayer: %v", err)
+			return
+		}
+		if err := r.UpdateSettings(host, SettingsUpdate{MaxPlayers: &two}); err == nil {
+			t.Error("room shrunk below its player count")
+		}
+	})
+}
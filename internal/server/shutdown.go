@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ShuttingDown reports whether the server has stopped taking new players and rooms
+func (s *Server) ShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closing
+}
+
+// Shutdown warns every room and lobby player, optionally lets running games
+// finish, then closes all connections with a going-away close frame. The
+// context deadline is what players are told and when connections get closed
+// at the latest.
+func (s *Server) Shutdown(ctx context.Context, waitForGames bool) {
+	s.mu.Lock()
+	s.closing = true
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	players := make([]*Player, 0, len(s.players))
+	for _, p := range s.players {
+		players = append(players, p)
+	}
+	s.mu.Unlock()
+
+	payload := map[string]interface{}{
+		"wait_for_games": waitForGames,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		payload["deadline"] = deadline.Unix()
+		payload["seconds_left"] = int(time.Until(deadline) / time.Second)
+	}
+	msg := map[string]interface{}{
+		"type": "server_shutdown",
+		"payload": payload,
+	}
+
+	for _, room := range rooms {
+		room.Do(func() { room.Broadcast(msg) })
+	}
+	for _, p := range players {
+		if p.CurrentRoom() == nil {
+			p.Send(msg)
+		}
+	}
+
+	if waitForGames {
+		slog.Info("waiting for running games to finish")
+		s.waitUntil(ctx, func() bool { return !anyGameRunning(rooms) })
+	}
+
+	slog.Info("closing connections", "count", len(players))
+	for _, p := range players {
+		p.Close(websocket.CloseGoingAway, "server shutting down")
+	}
+
+	// read pumps remove their players once the close frame went out
+	s.waitUntil(ctx, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return len(s.players) == 0
+	})
+}
+
+func anyGameRunning(rooms []*Room) bool {
+	for _, room := range rooms {
+		running := false
+		room.Do(func() { running = room.GameState.GameStarted })
+		if running {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) waitUntil(ctx context.Context, done func() bool) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
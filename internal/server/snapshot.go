@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"time"
+)
+
+// Snapshot describes the whole room as the given player sees it, so a client
+// that refreshed or joined late can rebuild its view without the deltas it
+// missed. Sent as state_snapshot on join and in reply to get_state.
+func (r *Room) Snapshot(p *Player) interface{} {
+	payload := map[string]interface{}{
+		"code": r.JoinCode,
+		"you": p.Number,
+		"options": r.Settings,
+		"players": r.PlayerList(),
+		"locked": r.Locked,
+		"round": r.Round,
+		"series_score": r.byNumber(r.SeriesScore),
+		"in_intermission": r.InIntermission(),
+		"muted": r.mutedBy(p),
+	}
+	if r.rematch != nil {
+		payload["rematch"] = map[string]interface{}{
+			"from": r.rematch.from.Number,
+			"options": r.rematch.settings,
+		}
+	}
+	if r.GameState.GameStarted {
+		payload["game"] = r.GameState.snapshot(r, p)
+	}
+
+	return map[string]interface{}{
+		"type": "state_snapshot",
+		"payload": payload,
+	}
+}
+
+// snapshot is the running game, the same fields game_start and word_claimed carry
+func (g *GameState) snapshot(r *Room, p *Player) map[string]interface{} {
+	claims := make([]map[string]interface{}, 0, len(g.Claimed))
+	for _, w := range g.Words {
+		word := strings.ToUpper(w)
+		id, ok := g.Claimed[word]
+		if !ok {
+			continue
+		}
+
+		claim := map[string]interface{}{
+			"word": word,
+			"start": g.wordCoords[word].Start,
+			"end": g.wordCoords[word].End,
+		}
+		if owner := r.playerByID(id); owner != nil {
+			claim["player_number"] = owner.Number
+			if g.Mode == ModeTeams {
+				claim["team"] = owner.Team
+			}
+		}
+		claims = append(claims, claim)
+	}
+
+	elapsed := time.Since(g.StartedAt)
+	game := map[string]interface{}{
+		"mode": g.Mode,
+		"board": g.Board,
+		"words": g.Words,
+		"claims": claims,
+		"score": r.byNumber(g.Score),
+		"elapsed": int(elapsed / time.Second),
+		"hints_left": r.hintsLeft(p),
+	}
+	if g.Mode == ModeTeams {
+		game["team_score"] = g.teamScore()
+	}
+	if g.Mode == ModeCoop {
+		left := time.Duration(g.TimeLimit) * time.Second - elapsed
+		if left < 0 {
+			left = 0
+		}
+		game["time_limit"] = g.TimeLimit
+		game["time_left"] = int(left / time.Second)
+	}
+	return game
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+)
+
+// Teams are numbered 1..MaxTeams, 0 means the player hasn't picked one
+const MaxTeams = MaxCapacity / 2
+
+func (r *Room) SetTeam(p *Player, team int) error {
+	if team < 1 || team > MaxTeams {
+		return errors.New("invalid team. must be between 1 and 4.")
+	}
+	if r.GameState.GameStarted {
+		return errors.New("game already started")
+	}
+
+	p.Team = team
+	r.Ready[p.ID] = false // the lineup changed, confirm again
+	return nil
+}
+
+// teamsReady checks that everyone picked a team and at least two teams are
+// playing
+func (r *Room) teamsReady() bool {
+	teams := make(map[int]bool)
+	for _, p := range r.Players {
+		if p.Team == 0 {
+			return false
+		}
+		teams[p.Team] = true
+	}
+	return len(teams) >= 2
+}
+
+// TeamPing points a cell out to p's teammates only
+func (r *Room) TeamPing(p *Player, cell Coord) error {
+	if r.GameState.Mode != ModeTeams || p.Team == 0 {
+		return errors.New("not on a team")
+	}
+
+	msg := map[string]interface{}{
+		"type": "team_ping",
+		"payload": map[string]interface{}{
+			"from": p.Number,
+			"team": p.Team,
+			"cell": [2]int{cell.Row, cell.Col},
+		},
+	}
+	for _, member := range r.Players {
+		if member.Team == p.Team {
+			member.Send(msg)
+		}
+	}
+	return nil
+}
+
+// checkTeamWinner applies the lockout rule to team scores: the first team
+// holding a majority of the words wins. With three or more teams a majority
+// may never happen, so a cleared board goes to the leading team (or a draw).
+func (g *GameState) checkTeamWinner(r *Room) (interface{}, bool) {
+	majority := len(g.Words)/2 + 1
+	remaining := len(g.Words) - len(g.Claimed)
+
+	winner, best, tied := 0, -1, false
+	for team, score := range g.TeamScore {
+		if score > best {
+			winner, best, tied = team, score, false
+		} else if score == best {
+			tied = true
+		}
+	}
+
+	if best >= majority {
+		g.WinningTeam = winner
+	} else if remaining == 0 {
+		if tied {
+			winner = 0
+		}
+		g.WinningTeam = winner
+	} else {
+		return nil, false
+	}
+	g.Winner = ""
+
+	msg := map[string]interface{}{
+		"type": "game_over",
+		"payload": map[string]interface{}{
+			"winner_team": g.WinningTeam,	// 0 on a draw
+			"team_score": g.teamScore(),
+			"score": r.byNumber(g.Score),
+			"unclaimed_words": g.getUnclaimedWordCoords(),
+		},
+	}
+
+	g.GameStarted = false
+	return msg, true
+}
+
+// teamScore copies the team scores for a message, the write pumps encode it
+// after the room has moved on
+func (g *GameState) teamScore() map[int]int {
+	scores := make(map[int]int, len(g.TeamScore))
+	for team, score := range g.TeamScore {
+		scores[team] = score
+	}
+	return scores
+}
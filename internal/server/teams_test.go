@@ -0,0 +1,88 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestCheckTeamWinner(t *testing.T) {
+	tests := []struct {
+		name string
+		teams []int		// team per player
+		words int
+		claims []int	// player index per claimed word
+		over bool
+		winner int		// team, 0 = draw
+	}{
+		{"no claims", []int{1, 2}, 7, nil, false, 0},
+		{"short of a majority", []int{1, 1, 2, 2}, 7, []int{0, 1, 2}, false, 0},
+		{"majority", []int{1, 1, 2, 2}, 7, []int{0, 1, 2, 0, 1}, true, 1},
+		{"majority split across the team", []int{1, 1, 2, 2}, 7, []int{2, 3, 2, 3, 0}, true, 2},
+		{"three teams cleared, leader wins", []int{1, 2, 3}, 6, []int{0, 0, 0, 1, 1, 2}, true, 1},
+		{"three teams cleared, tie", []int{1, 2, 3}, 6, []int{0, 1, 2, 0, 1, 2}, true, 0},
+		{"three teams, board left", []int{1, 2, 3}, 6, []int{0, 0, 0, 1}, false, 0},
+	}
+	for _, tt := range tests {
+		players := testPlayers(len(tt.teams))
+		for i, team := range tt.teams {
+			players[i].Team = team
+		}
+		r, g := testGame(ModeTeams, tt.words, players...)
+		for _, p := range players {
+			g.TeamScore[p.Team] = 0
+		}
+		for _, i := range tt.claims {
+			g.claim(players[i])
+		}
+
+		msg, over := g.checkTeamWinner(r)
+		if over != tt.over {
+			t.Errorf("%s: over = %v", tt.name, over)
+			continue
+		}
+		if !over {
+			continue
+		}
+
+		payload := msg.(map[string]interface{})["payload"].(map[string]interface{})
+		if payload["winner_team"] != tt.winner || g.WinningTeam != tt.winner {
+			t.Errorf("%s: winner_team = %v, want %d", tt.name, payload["winner_team"], tt.winner)
+		}
+		if g.GameStarted || g.Winner != "" {
+			t.Errorf("%s: game still running or has a single winner", tt.name)
+		}
+	}
+}
+
+func TestRematchNeedsTeams(t *testing.T) {
+	r := testRoom(t)
+	players := testPlayers(2)
+	r.Do(func() {
+		r.Settings.Mode = ModeTeams
+		for _, p := range players {
+			if err := r.AddPlayer(p); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+		}
+
+		r.SetTeam(players[0], 1)
+		if r.RequestRematch(players[0], 0, 0) == nil {
+			t.Errorf("rematch requested with a player off the teams")
+		}
+		r.SetTeam(players[1], 2)
+		if err := r.RequestRematch(players[0], 0, 0); err != nil {
+			t.Errorf("request: %v", err)
+			return
+		}
+
+		// one team left by the time the rematch is accepted
+		r.SetTeam(players[1], 1)
+		if r.AcceptRematch(players[1]) == nil || r.GameState.GameStarted {
+			t.Errorf("rematch started with a single team")
+		}
+		r.SetTeam(players[1], 2)
+		if err := r.AcceptRematch(players[1]); err != nil || !r.GameState.GameStarted {
+			t.Errorf("accept: %v, started %v", err, r.GameState.GameStarted)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package validate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+// Validator checks free-form player input before it reaches any room
+type Validator struct {
+	cfg config.Validation
+	codeLength int
+	chatLength int
+	namePattern *regexp.Regexp
+	blocked []string		// normalized, see normalize
+}
+
+func New(cfg *config.Config) (*Validator, error) {
+	v := &Validator{
+		cfg: cfg.Validation,
+		codeLength: cfg.Rooms.CodeLength,
+		chatLength: cfg.Chat.MaxLength,
+	}
+
+	pattern, err := regexp.Compile(cfg.Validation.NamePattern)
+	if err != nil {
+		return nil, fmt.Errorf("validation.name_pattern: %v", err)
+	}
+	v.namePattern = pattern
+
+	words := cfg.Validation.BlockedWords
+	if path := cfg.Validation.BlockedWordsFile; path != "" {
+		fromFile, err := readLines(path)
+		if err != nil {
+			return nil, fmt.Errorf("validation.blocked_words_file: %v", err)
+		}
+		words = append(append([]string(nil), words...), fromFile...)
+	}
+	for _, w := range words {
+		if w = normalize(w); w != "" {
+			v.blocked = append(v.blocked, w)
+		}
+	}
+	return v, nil
+}
+
+// Name returns name with surrounding and repeated whitespace removed, or
+// an error saying why it can't be used. Blocked words count as whole words,
+// like in Chat.
+func (v *Validator) Name(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+
+	n := utf8.RuneCountInString(name)
+	if n < v.cfg.NameMinLength || n > v.cfg.NameMaxLength {
+		return "", fmt.Errorf("name must be between %d and %d characters", v.cfg.NameMinLength, v.cfg.NameMaxLength)
+	}
+	if !utf8.ValidString(name) || !v.namePattern.MatchString(name) {
+		return "", errors.New("name contains characters that aren't allowed")
+	}
+	for _, w := range strings.Fields(name) {
+		if v.blockedWord(w) {
+			return "", errors.New("name isn't allowed")
+		}
+	}
+	return name, nil
+}
+
+// Chat returns a chat message with control characters and extra whitespace
+// removed and blocked words masked with asterisks. Only whole words are
+// masked, so a blocked "ass" leaves "pass" and "was sad" alone.
+func (v *Validator) Chat(text string) (string, error) {
+	text = strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return ' '
+		}
+		return c
+	}, text)
+	words := strings.Fields(text)
+
+	n := utf8.RuneCountInString(strings.Join(words, " "))
+	if n == 0 {
+		return "", errors.New("message is empty")
+	}
+	if n > v.chatLength {
+		return "", fmt.Errorf("message can't be longer than %d characters", v.chatLength)
+	}
+
+	for i, w := range words {
+		if v.blockedWord(w) {
+			words[i] = strings.Repeat("*", utf8.RuneCountInString(w))
+		}
+	}
+	return strings.Join(words, " "), nil
+}
+
+// blockedWord reports whether a single word is a blocked word. Matching
+// ignores case, punctuation and common digit substitutions, so "B.a-D" is
+// "bad", but never looks inside longer words like "Cassandra".
+func (v *Validator) blockedWord(w string) bool {
+	w = normalize(w)
+	for _, b := range v.blocked {
+		if w == b {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinCode returns code in the form the server hands out, upper case
+func (v *Validator) JoinCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != v.codeLength {
+		return "", errors.New("invalid room code")
+	}
+	for _, c := range code {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return "", errors.New("invalid room code")
+		}
+	}
+	return code, nil
+}
+
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+func normalize(s string) string {
+	s = leet.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+	for _, c := range s {
+		if c >= 'a' && c <= 'z' || c > utf8.RuneSelf {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,87 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+)
+
+func newValidator(t *testing.T, blocked ...string) *Validator {
+	cfg := config.Default()
+	cfg.Validation.BlockedWords = blocked
+	cfg.Chat.MaxLength = 20
+	v, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return v
+}
+
+func TestChat(t *testing.T) {
+	v := newValidator(t, "ass")
+
+	tests := []struct {
+		in string
+		want string
+	}{
+		{"hello", "hello"},
+		{"  lots   of\tspace ", "lots of space"},
+		{"line\nbreak\x00", "line break"},
+		{"I was sad", "I was sad"},
+		{"pass the ball", "pass the ball"},
+		{"classic", "classic"},
+		{"you ass", "you ***"},
+		{"ASS!", "****"},
+		{"a$$ 4ss", "*** ***"},
+		{"A.S.S", "*****"},
+		{"käse", "käse"},
+	}
+	for _, tt := range tests {
+		got, err := v.Chat(tt.in)
+		if err != nil {
+			t.Errorf("Chat(%q): %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("Chat(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", " \n\t", strings.Repeat("x", 21), strings.Repeat("ü", 21)} {
+		if _, err := v.Chat(in); err == nil {
+			t.Errorf("Chat(%q) accepted", in)
+		}
+	}
+	if _, err := v.Chat(strings.Repeat("ü", 20)); err != nil {
+		t.Errorf("20 letters rejected: %v", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	v := newValidator(t, "bad", "ass")
+
+	if got, err := v.Name("  Ada   Lovelace "); err != nil || got != "Ada Lovelace" {
+		t.Errorf("Name = %q, %v", got, err)
+	}
+	for _, in := range []string{"Cassandra", "Badger", "Mr Bassett"} {
+		if _, err := v.Name(in); err != nil {
+			t.Errorf("Name(%q): %v", in, err)
+		}
+	}
+	for _, in := range []string{"", strings.Repeat("a", 21), "<script>", "B.a-D", "the bad one", "4SS"} {
+		if _, err := v.Name(in); err == nil {
+			t.Errorf("Name(%q) accepted", in)
+		}
+	}
+}
+
+func TestJoinCode(t *testing.T) {
+	v := newValidator(t)
+
+	if got, err := v.JoinCode(" ab12cd "); err != nil || got != "AB12CD" {
+		t.Errorf("JoinCode = %q, %v", got, err)
+	}
+	for _, in := range []string{"", "AB12C", "AB12CDE", "AB-2CD", "ÄB12CD"} {
+		if _, err := v.JoinCode(in); err == nil {
+			t.Errorf("JoinCode(%q) accepted", in)
+		}
+	}
+}
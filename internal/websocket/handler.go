@@ -1,336 +1,814 @@
-package websocket
-
-import(
-	"log"
-	"net/http"
-	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
-	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
-	"encoding/json"
-)
-
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
-type Handler struct {
-	server *server.Server
-	routes map[string]func(*server.Player, json.RawMessage)
-}
-
-func New(s *server.Server) *Handler {
-	h := &Handler{
-		server: s,
-		routes: make(map[string]func(*server.Player, json.RawMessage)),
-	}
-
-	h.routes["create_room"] = h.handleCreateRoom
-	h.routes["join_room"]   = h.handleJoinRoom
-	h.routes["name_change"] = h.handleNameChange
-	h.routes["set_ready"] 	= h.handleSetReady
-	h.routes["select_word"] = h.handleSelectWord
-	h.routes["set_word_count"] = h.handleSetWordCount
-	h.routes["set_grid_size"] = h.handleSetGridSize
-	h.routes["leave_room"] = h.handleLeaveRoom
-	h.routes["ping"]        = h.handlePing
-
-	return h
-}
-
-func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("upgrade error:", err)
-		return
-	}
-
-	player := &server.Player{
-		ID: uuid.NewString(),
-		Conn: conn,
-		Send: make(chan interface{}, 16),
-	}
-
-	h.server.AddPlayer(player)
-	go player.WritePump() // Start player write pump
-	defer func() { // cleanup
-		close(player.Send)
-		h.server.RemovePlayer(player)
-	}()
-
-	h.ReadPump(player)
-}
-
-func (h *Handler) ReadPump(player *server.Player) {
-	for {
-		var msg Message
-		if err := player.Conn.ReadJSON(&msg); err != nil {
-			log.Println("read error:", err)
-			return
-		}
-
-		handler, ok := h.routes[msg.Type]
-		if !ok {
-			player.Send <- errorMessage("unknown message type")
-			continue
-		}
-
-		handler(player, msg.Payload)
-	}
-}
-
-func (h *Handler) handleCreateRoom(player *server.Player, payload json.RawMessage) {
-	roomID := uuid.NewString()
-
-	room, err := h.server.CreateRoom(player, roomID)
-	if err != nil {
-		player.Send <- errorMessage(err.Error())
-		return
-	}
-
-	var data struct {
-		Name string `json:"name"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid payload")
-		return
-	}
-
-	player.Name = data.Name
-	player.Send <- map[string]interface{}{
-		"type": "room_created",
-		"payload": map[string]interface{}{
-			"code": room.JoinCode,
-			"player1_name": room.Player1.Name,
-			"options": map[string]interface{}{
-				"grid_size": room.GameState.GridSize,
-				"word_count": room.GameState.WordCount,
-			},
-		},
-	}
-}
-
-func (h *Handler) handleJoinRoom(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		JoinCode string `json:"join_code"`
-		Name string `json:"name"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid payload")
-		return
-	}
-
-	room, err := h.server.JoinRoomByCode(player, data.JoinCode)
-	if err != nil {
-		player.Send <- errorMessage(err.Error())
-		return
-	}
-
-	player.Name = data.Name
-	log.Println("player joined room. player: ", player.ID, " name: ", data.Name, "room: ", room.ID)
-
-	room.Broadcast(map[string]interface{}{
-		"type": "player_joined",
-		"payload": map[string]interface{}{
-			"player1_name": room.Player1.Name,
-			"player2_name": room.Player2.Name,
-			"player1_ready": room.PlayerReady[0],
-			"player2_ready": room.PlayerReady[1],
-			"code": room.JoinCode,
-			"options": map[string]interface{}{
-				"grid_size": room.GameState.GridSize,
-				"word_count": room.GameState.WordCount,
-			},
-		},
-	})
-}
-
-func (h *Handler) handleSelectWord(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		Start server.Coord `json:"start"`
-		End   server.Coord `json:"end"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid selection")
-		return
-	}
-
-	room := player.Room
-	if room == nil {
-		player.Send <- errorMessage("not in a game")
-		return
-	}
-
-	if msg, err := room.GameState.ClaimWord(player, data.Start, data.End, room); err != nil {
-		player.Send <- errorMessage(err.Error())
-	} else {
-		room.Broadcast(msg)
-
-		msg, gameOver := room.GameState.CheckForWinner(room)
-		if gameOver {
-			room.Broadcast(msg)
-			room.PlayerReady = [2]bool{false, false}
-		}
-	}
-}
-
-func (h *Handler) handleSetReady(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		Ready bool `json:"ready"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid selection")
-		return
-	}
-
-	room := player.Room
-	if room == nil {
-		player.Send <- errorMessage("not in a game")
-		return
-	}
-
-	if room.GameState != nil && room.GameState.GameStarted {
-		player.Send <- errorMessage("game already started")
-		return
-	}
-
-	room.SetReady(player, data.Ready)
-	room.Broadcast(map[string]interface{}{
-		"type": "ready_update",
-		"payload": map[string]interface{}{
-			"player1_ready": room.PlayerReady[0],
-			"player2_ready": room.PlayerReady[1],
-		},
-	})
-
-	start := room.CheckStartCondition()
-	if start {
-		room.Broadcast(room.GameState.StartGame())
-	}
-}
-
-func (h *Handler) handleSetWordCount(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		WordCount int `json:"word_count"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid selection")
-		return
-	}
-
-	room := player.Room
-	if room == nil {
-		player.Send <- errorMessage("not in a game")
-		return
-	}
-	
-	if room.Player1.ID != player.ID || player.Number != 1 {
-		player.Send <- errorMessage("only Player 1 can modify game settings")
-		return
-	}
-
-	if room.GameState != nil && room.GameState.GameStarted {
-		player.Send <- errorMessage("game already started")
-		return
-	}
-
-	room.GameState.WordCount = data.WordCount
-	room.Broadcast(map[string]interface{}{
-		"type": "game_settings",
-		"payload": map[string]interface{}{
-			"options": map[string]interface{}{
-				"grid_size": room.GameState.GridSize,
-				"word_count": room.GameState.WordCount,
-			},
-		},
-	})
-}
-
-func (h *Handler) handleSetGridSize(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		GridSize int `json:"grid_size"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid selection")
-		return
-	}
-
-	if data.GridSize <= 10 {
-		player.Send <- errorMessage("insufficient grid size. must be greater than 10.")
-		return
-	}
-
-	room := player.Room
-	if room == nil {
-		player.Send <- errorMessage("not in a game")
-		return
-	}
-	
-	if room.Player1.ID != player.ID || player.Number != 1 {
-		player.Send <- errorMessage("only Player 1 can modify game settings")
-		return
-	}
-
-	if room.GameState != nil && room.GameState.GameStarted {
-		player.Send <- errorMessage("game already started")
-		return
-	}
-
-	room.GameState.GridSize = data.GridSize
-	room.Broadcast(map[string]interface{}{
-		"type": "game_settings",
-		"payload": map[string]interface{}{
-			"options": map[string]interface{}{
-				"grid_size": room.GameState.GridSize,
-				"word_count": room.GameState.WordCount,
-			},
-		},
-	})
-}
-
-func (h *Handler) handleLeaveRoom(player *server.Player, _ json.RawMessage) {
-	h.server.RemovePlayerFromRoom(player)
-}
-
-func (h *Handler) handleNameChange(player *server.Player, payload json.RawMessage) {
-	var data struct {
-		Name string `json:"name"`
-	}
-
-	if err := json.Unmarshal(payload, &data); err != nil {
-		player.Send <- errorMessage("invalid name change")
-		return
-	}
-
-	player.Name = data.Name
-
-	player.Send <- map[string]interface{}{
-		"type": "name_change_accepted",
-		"payload": map[string]string{
-			"name": player.Name,
-		},
-	}
-	
-}
-
-func (h *Handler) handlePing(player *server.Player, _ json.RawMessage) {
-	player.Send <- map[string]interface{}{
-		"type": "pong",
-	}
-}
-
-func errorMessage(msg string) map[string]interface{} {
-	return map[string]interface{}{
-		"type": "error",
-		"payload": map[string]string{
-			"message": msg,
-		},
-	}
-}
+package websocket
+
+import(
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/auth"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/ratelimit"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/validate"
+	"encoding/json"
+)
+
+// Message types clients may send as fast as they like, over the limit they're
+// dropped quietly instead of counting as violations
+var lossy = map[string]bool{
+	"selection_preview": true,
+}
+
+type Handler struct {
+	server *server.Server
+	routes map[string]func(*server.Player, json.RawMessage)
+	upgrader websocket.Upgrader
+	verifier *auth.Verifier		// nil when auth is disabled
+	validator *validate.Validator
+	cfg *config.Config
+
+	conns *ratelimit.Counter	// open sockets per IP
+	joins *ratelimit.Lockout	// failed join codes per IP
+
+	Heartbeat server.Heartbeat
+}
+
+func New(s *server.Server, cfg *config.Config) (*Handler, error) {
+	validator, err := validate.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		validator: validator,
+		server: s,
+		routes: make(map[string]func(*server.Player, json.RawMessage)),
+		cfg: cfg,
+		conns: ratelimit.NewCounter(cfg.Limits.MaxConnectionsPerIP),
+		joins: ratelimit.NewLockout(cfg.Limits.MaxFailedJoins, cfg.Limits.FailedJoinWindow.Duration, cfg.Limits.JoinLockout.Duration),
+		Heartbeat: server.Heartbeat{
+			PingInterval: cfg.Connection.PingInterval.Duration,
+			PongWait: cfg.Connection.PongWait.Duration,
+			WriteWait: cfg.Connection.WriteWait.Duration,
+		},
+	}
+	h.upgrader.CheckOrigin = h.checkOrigin
+	if cfg.Auth.Secret != "" {
+		h.verifier = auth.NewVerifier(cfg.Auth.Secret)
+	}
+
+	h.routes["create_room"] = h.handleCreateRoom
+	h.routes["join_room"]   = h.handleJoinRoom
+	h.routes["name_change"] = h.handleNameChange
+	h.routes["set_ready"] 	= h.handleSetReady
+	h.routes["select_word"] = h.handleSelectWord
+	h.routes["update_settings"] = h.handleUpdateSettings
+	h.routes["set_word_count"] = h.handleUpdateSettings
+	h.routes["set_grid_size"] = h.handleUpdateSettings
+	h.routes["set_series_length"] = h.handleUpdateSettings
+	h.routes["set_max_players"] = h.handleUpdateSettings
+	h.routes["set_mode"] = h.handleUpdateSettings
+	h.routes["set_time_limit"] = h.handleUpdateSettings
+	h.routes["set_penalty"] = h.handleUpdateSettings
+	h.routes["set_team"] = h.handleSetTeam
+	h.routes["team_ping"] = h.handleTeamPing
+	h.routes["request_rematch"] = h.handleRequestRematch
+	h.routes["accept_rematch"] = h.handleAcceptRematch
+	h.routes["decline_rematch"] = h.handleDeclineRematch
+	h.routes["transfer_host"] = h.handleTransferHost
+	h.routes["kick_player"] = h.handleKickPlayer
+	h.routes["lock_room"] = h.handleLockRoom
+	h.routes["regenerate_code"] = h.handleRegenerateCode
+	h.routes["leave_room"] = h.handleLeaveRoom
+	h.routes["get_state"] = h.handleGetState
+	h.routes["request_hint"] = h.handleRequestHint
+	h.routes["selection_preview"] = h.handleSelectionPreview
+	h.routes["chat"] = h.handleChat
+	h.routes["emote"] = h.handleEmote
+	h.routes["mute_player"] = h.handleMutePlayer
+	h.routes["ping"]        = h.handlePing
+
+	return h, nil
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	if h.server.ShuttingDown() {
+		rejectedConnections.Inc("shutting_down")
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	identity, verified, err := h.authenticate(r)
+	if err != nil {
+		slog.Warn("rejected connection", "remote_addr", r.RemoteAddr, "error", err)
+		rejectedConnections.Inc("unauthorized")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ip := h.clientIP(r)
+	if !h.conns.Acquire(ip) {
+		slog.Warn("too many connections", "ip", ip)
+		rejectedConnections.Inc("too_many_connections")
+		http.Error(w, "too many connections", http.StatusTooManyRequests)
+		return
+	}
+	defer h.conns.Release(ip)
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("upgrade failed", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+
+	player := server.NewPlayer(identity.ID, conn, h.cfg.Connection.SendQueueSize)
+	player.Name = identity.Name
+	player.IP = ip
+	player.Verified = verified
+
+	h.server.AddPlayer(player)
+	go player.WritePump(h.Heartbeat) // Start player write pump
+	defer func() { // cleanup
+		h.server.RemovePlayer(player)
+		player.Disconnect()
+	}()
+
+	h.ReadPump(player)
+}
+
+func (h *Handler) ReadPump(player *server.Player) {
+	player.Conn.SetReadLimit(h.cfg.Limits.MaxMessageSize)
+	player.ExtendReadDeadline(h.Heartbeat)
+	player.Conn.SetPongHandler(func(string) error {
+		player.ExtendReadDeadline(h.Heartbeat)
+		return nil
+	})
+
+	buckets := make(map[string]*ratelimit.Bucket)
+	violations := 0
+
+	for {
+		var msg Message
+		if err := player.Conn.ReadJSON(&msg); err != nil {
+			player.Logger().Info("player disconnected", "error", err)
+			return
+		}
+		player.ExtendReadDeadline(h.Heartbeat)
+		player.Touch()
+
+		// unknown types are charged to the default bucket, so junk can't be
+		// sent faster than anything else
+		handler, ok := h.routes[msg.Type]
+		msgType := msg.Type
+		if !ok {
+			msgType = "unknown"
+		}
+		messagesIn.Inc(msgType)
+		player.Logger().Debug("message received", "message_type", msgType)
+
+		if !h.bucket(buckets, msgType).Allow() {
+			rateLimited.Inc(msgType)
+			if lossy[msgType] {
+				continue
+			}
+			violations++
+			if violations >= h.cfg.Limits.MaxViolations {
+				player.Logger().Warn("disconnecting player, too many rate limited messages", "ip", player.IP, "message_type", msgType)
+				player.Close(websocket.ClosePolicyViolation, "rate limit exceeded")
+				<-player.Done() // let the write pump flush the errors and close frame
+				return
+			}
+			player.Send(errorCode("rate_limited", "too many "+msgType+" messages, slow down"))
+			continue
+		}
+
+		if !ok {
+			player.Send(errorMessage("unknown message type"))
+			continue
+		}
+		handler(player, msg.Payload)
+	}
+}
+
+func (h *Handler) handleCreateRoom(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid payload"))
+		return
+	}
+
+	// set before the room exists, afterwards the room goroutine reads it
+	if player.CurrentRoom() != nil {
+		player.Send(errorMessage("player already in room"))
+		return
+	}
+	if err := h.lobbyName(player, data.Name); err != nil {
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	if _, err := h.server.CreateRoom(player, uuid.NewString()); err != nil {
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		player.Send(map[string]interface{}{
+			"type": "room_created",
+			"payload": map[string]interface{}{
+				"code": room.JoinCode,
+				"players": room.PlayerList(),
+				"options": room.Settings,
+			},
+		})
+	})
+}
+
+func (h *Handler) handleJoinRoom(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		JoinCode string `json:"join_code"`
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid payload"))
+		return
+	}
+
+	if left := h.joins.LockedFor(player.IP); left > 0 {
+		player.Send(errorCode("join_locked_out", fmt.Sprintf("too many invalid room codes, try again in %d seconds", int(left.Seconds())+1)))
+		return
+	}
+
+	code, err := h.validator.JoinCode(data.JoinCode)
+	if err != nil {
+		h.failedJoin(player)
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	if player.CurrentRoom() == nil {
+		if err := h.lobbyName(player, data.Name); err != nil {
+			player.Send(errorMessage(err.Error()))
+			return
+		}
+	}
+
+	_, err = h.server.JoinRoomByCode(player, code)
+	if err == server.ErrInvalidCode {
+		h.failedJoin(player)
+	}
+	if err != nil {
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	player.Logger().Info("player joined room", "name", player.Name)
+
+	h.inRoom(player, func(room *server.Room) {
+		room.Broadcast(map[string]interface{}{
+			"type": "player_joined",
+			"payload": map[string]interface{}{
+				"players": room.PlayerList(),
+				"code": room.JoinCode,
+				"options": room.Settings,
+			},
+		})
+		player.Send(room.Snapshot(player))
+	})
+}
+
+func (h *Handler) handleSelectWord(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Start server.Coord `json:"start"`
+		End   server.Coord `json:"end"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	received := time.Now()
+	h.inRoom(player, func(room *server.Room) {
+		msg, err := room.SelectWord(player, data.Start, data.End)
+		claimLatency.Observe(time.Since(received).Seconds())
+
+		if err != nil {
+			player.Send(errorMessage(err.Error()))
+		} else {
+			room.Broadcast(msg)
+
+			msg, gameOver := room.GameState.CheckForWinner(room)
+			if gameOver {
+				room.Broadcast(msg)
+				room.EndRound()
+			}
+		}
+	})
+}
+
+func (h *Handler) handleSelectionPreview(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Start server.Coord `json:"start"`
+		End   server.Coord `json:"end"`
+	}
+
+	// lossy, a bad preview isn't worth an error
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		room.Preview(player, data.Start, data.End)
+	})
+}
+
+func (h *Handler) handleSetReady(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Ready bool `json:"ready"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if room.GameState != nil && room.GameState.GameStarted {
+			player.Send(errorMessage("game already started"))
+			return
+		}
+
+		if room.InIntermission() {
+			player.Send(errorMessage("next round is about to start"))
+			return
+		}
+
+		if data.Ready && room.Settings.Mode == server.ModeTeams && player.Team == 0 {
+			player.Send(errorMessage("pick a team first"))
+			return
+		}
+
+		room.SetReady(player, data.Ready)
+		room.Broadcast(map[string]interface{}{
+			"type": "ready_update",
+			"payload": map[string]interface{}{
+				"players": room.PlayerList(),
+			},
+		})
+
+		start := room.CheckStartCondition()
+		if start {
+			room.StartGame()
+		}
+	})
+}
+
+// handleUpdateSettings takes any subset of the room settings. The older
+// set_* messages carry a single field of the same payload and end up here too.
+func (h *Handler) handleUpdateSettings(player *server.Player, payload json.RawMessage) {
+	var data server.SettingsUpdate
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid settings"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.UpdateSettings(player, data); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleSetTeam(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Team int `json:"team"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.SetTeam(player, data.Team); err != nil {
+			player.Send(errorMessage(err.Error()))
+			return
+		}
+
+		room.Broadcast(map[string]interface{}{
+			"type": "team_update",
+			"payload": map[string]interface{}{
+				"players": room.PlayerList(),
+			},
+		})
+	})
+}
+
+func (h *Handler) handleTeamPing(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Cell server.Coord `json:"cell"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid ping"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.TeamPing(player, data.Cell); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleRequestRematch(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		WordCount int `json:"word_count"`
+		GridSize int `json:"grid_size"`
+	}
+
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &data); err != nil {
+			player.Send(errorMessage("invalid rematch request"))
+			return
+		}
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.RequestRematch(player, data.WordCount, data.GridSize); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleAcceptRematch(player *server.Player, _ json.RawMessage) {
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.AcceptRematch(player); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleDeclineRematch(player *server.Player, _ json.RawMessage) {
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.DeclineRematch(player); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleTransferHost(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		PlayerNumber int `json:"player_number"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.TransferHost(player, data.PlayerNumber); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleKickPlayer(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		PlayerNumber int `json:"player_number"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.Kick(player, data.PlayerNumber); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleLockRoom(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Locked bool `json:"locked"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid selection"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.SetLocked(player, data.Locked); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleRegenerateCode(player *server.Player, _ json.RawMessage) {
+	if err := h.server.RegenerateCode(player); err != nil {
+		player.Send(errorMessage(err.Error()))
+	}
+}
+
+// handleGetState resends the full room and game state, e.g. after a client refresh
+func (h *Handler) handleGetState(player *server.Player, _ json.RawMessage) {
+	h.inRoom(player, func(room *server.Room) {
+		player.Send(room.Snapshot(player))
+	})
+}
+
+func (h *Handler) handleRequestHint(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Kind string `json:"kind"`
+		Word string `json:"word"`	// optional, a random unclaimed word otherwise
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid hint request"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.Hint(player, data.Kind, data.Word); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleChat(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Text string `json:"text"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid message"))
+		return
+	}
+
+	text, err := h.validator.Chat(data.Text)
+	if err != nil {
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		room.Chat(player, text)
+	})
+}
+
+func (h *Handler) handleEmote(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Emote string `json:"emote"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid emote"))
+		return
+	}
+
+	known := false
+	for _, emote := range h.cfg.Chat.Emotes {
+		if emote == data.Emote {
+			known = true
+			break
+		}
+	}
+	if !known {
+		player.Send(errorMessage("unknown emote"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		room.Emote(player, data.Emote)
+	})
+}
+
+func (h *Handler) handleMutePlayer(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		PlayerNumber int `json:"player_number"`
+		Muted bool `json:"muted"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid payload"))
+		return
+	}
+
+	h.inRoom(player, func(room *server.Room) {
+		if err := room.Mute(player, data.PlayerNumber, data.Muted); err != nil {
+			player.Send(errorMessage(err.Error()))
+		}
+	})
+}
+
+func (h *Handler) handleLeaveRoom(player *server.Player, _ json.RawMessage) {
+	h.server.RemovePlayerFromRoom(player)
+}
+
+func (h *Handler) handleNameChange(player *server.Player, payload json.RawMessage) {
+	var data struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(payload, &data); err != nil {
+		player.Send(errorMessage("invalid name change"))
+		return
+	}
+
+	name, err := h.validator.Name(data.Name)
+	if err != nil {
+		player.Send(errorMessage(err.Error()))
+		return
+	}
+
+	accept := func() {
+		player.Name = name
+		player.Send(map[string]interface{}{
+			"type": "name_change_accepted",
+			"payload": map[string]string{
+				"name": player.Name,
+			},
+		})
+	}
+
+	// the room goroutine reads names while building payloads
+	if room := player.CurrentRoom(); room == nil || !room.Do(accept) {
+		accept()
+	}
+}
+
+func (h *Handler) handlePing(player *server.Player, _ json.RawMessage) {
+	player.TrySend(map[string]interface{}{
+		"type": "pong",
+	})
+}
+
+// checkOrigin only restricts browsers, which always send an Origin header.
+// Native clients don't and are left to token authentication.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(h.cfg.AllowedOrigins) == 0 || origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range h.cfg.AllowedOrigins {
+		a, err := url.Parse(allowed)
+		if err != nil || !strings.EqualFold(a.Scheme, u.Scheme) {
+			continue
+		}
+
+		if strings.HasPrefix(a.Host, "*.") { // any subdomain
+			if strings.HasSuffix(strings.ToLower(u.Host), strings.ToLower(a.Host[1:])) {
+				return true
+			}
+		} else if strings.EqualFold(a.Host, u.Host) {
+			return true
+		}
+	}
+	slog.Warn("rejected origin", "origin", origin)
+	return false
+}
+
+// authenticate returns who is connecting. Without a token (and with auth
+// optional) the player gets a random ID as before.
+// authenticate also reports whether the identity came from a verified token,
+// anonymous players get a fresh ID and can't come back as themselves
+func (h *Handler) authenticate(r *http.Request) (*auth.Identity, bool, error) {
+	token := r.URL.Query().Get("token")
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token = strings.TrimPrefix(bearer, "Bearer ")
+	}
+
+	if token == "" || h.verifier == nil {
+		if h.cfg.Auth.Required {
+			return nil, false, fmt.Errorf("authentication required")
+		}
+		return &auth.Identity{ID: uuid.NewString()}, false, nil
+	}
+
+	identity, err := h.verifier.Verify(token)
+	return identity, err == nil, err
+}
+
+// clientIP is the peer address, or when the server sits behind trusted
+// proxies the X-Forwarded-For entry added by the outermost one. Entries left
+// of it come from the client, so they're counted from the right.
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.cfg.Limits.TrustProxy {
+		var hops []string
+		for _, fwd := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(fwd, ",")...)
+		}
+		if len(hops) > 0 {
+			i := len(hops) - h.cfg.Limits.ProxyHops
+			if i < 0 {
+				i = 0
+			}
+			if ip := strings.TrimSpace(hops[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bucket returns the connection's token bucket for a message type, types
+// without their own rate share the "default" bucket
+func (h *Handler) bucket(buckets map[string]*ratelimit.Bucket, msgType string) *ratelimit.Bucket {
+	rate, ok := h.cfg.Limits.Messages[msgType]
+	if !ok {
+		msgType, rate = "default", h.cfg.Limits.Messages["default"]
+	}
+
+	b, ok := buckets[msgType]
+	if !ok {
+		b = ratelimit.NewBucket(rate.PerSecond, rate.Burst)
+		buckets[msgType] = b
+	}
+	return b
+}
+
+// failedJoin counts a wrong room code against the player's IP
+func (h *Handler) failedJoin(player *server.Player) {
+	if h.joins.Fail(player.IP) {
+		player.Logger().Warn("locking out IP after too many invalid room codes", "ip", player.IP)
+	}
+}
+
+// lobbyName sets the name of a player outside any room. Authenticated
+// players may leave it empty to keep their account name.
+func (h *Handler) lobbyName(player *server.Player, name string) error {
+	if name == "" && player.Name != "" {
+		return nil
+	}
+
+	name, err := h.validator.Name(name)
+	if err != nil {
+		return err
+	}
+	player.Name = name
+	return nil
+}
+
+// inRoom runs fn on the goroutine of the player's room, so fn may read and
+// modify room and game state freely
+func (h *Handler) inRoom(player *server.Player, fn func(*server.Room)) {
+	room := player.CurrentRoom()
+	ok := room != nil && room.Do(func() {
+		if player.CurrentRoom() != room { // left while we were queued
+			player.Send(errorMessage("not in a game"))
+			return
+		}
+		room.Touch()
+		fn(room)
+	})
+
+	if !ok {
+		player.Send(errorMessage("not in a game"))
+	}
+}
+
+func errorMessage(msg string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "error",
+		"payload": map[string]string{
+			"message": msg,
+		},
+	}
+}
+
+// errorCode is an error clients can tell apart without parsing the message
+func errorCode(code, msg string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "error",
+		"payload": map[string]string{
+			"code": code,
+			"message": msg,
+		},
+	}
+}
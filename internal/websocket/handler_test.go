@@ -0,0 +1,431 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/auth"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/config"
+	"github.com/Gexff/word-search-1v1-go-websocket-server/internal/server"
+)
+
+// testClient is a player connected to the test server. Messages are read in
+// the background so the server never sees a slow client, sends may come from
+// several goroutines.
+type testClient struct {
+	conn *websocket.Conn
+	in chan Message
+	closed chan struct{}
+	closeOnce sync.Once
+	mu sync.Mutex
+}
+
+func dialTest(url string) (*testClient, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &testClient{conn: conn, in: make(chan Message, 1024), closed: make(chan struct{})}
+	go func() {
+		defer close(c.in)
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			select {
+			case c.in <- msg:
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+func (c *testClient) send(msgType string, payload interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteJSON(map[string]interface{}{"type": msgType, "payload": payload})
+}
+
+// wait skips messages until one of the given types arrives
+func (c *testClient) wait(types ...string) (Message, error) {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.in:
+			if !ok {
+				return Message{}, fmt.Errorf("connection closed waiting for %v", types)
+			}
+			for _, t := range types {
+				if msg.Type == t {
+					return msg, nil
+				}
+			}
+		case <-timeout:
+			return Message{}, fmt.Errorf("timed out waiting for %v", types)
+		}
+	}
+}
+
+func (c *testClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// signedURL connects with a token for a new player ID, so the player can
+// reconnect as themselves
+func signedURL(url string) string {
+	token, _ := auth.NewVerifier(testSecret).Sign(auth.Identity{ID: uuid.NewString()})
+	return url + "?token=" + token
+}
+
+// openRoom creates a room with settings and fills it up to n anonymous players
+func openRoom(url string, n int, settings map[string]interface{}) ([]*testClient, error) {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = url
+	}
+	return openRoomAs(urls, settings)
+}
+
+// openRoomAs is openRoom with a player connecting to each of urls
+func openRoomAs(urls []string, settings map[string]interface{}) ([]*testClient, error) {
+	var clients []*testClient
+	for _, url := range urls {
+		c, err := dialTest(url)
+		if err != nil {
+			return clients, err
+		}
+		clients = append(clients, c)
+	}
+
+	host := clients[0]
+	host.send("create_room", map[string]string{"name": "host"})
+	msg, err := host.wait("room_created")
+	if err != nil {
+		return clients, err
+	}
+	var room struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(msg.Payload, &room)
+
+	// before anyone joins, settings changes unready the others
+	if settings != nil {
+		host.send("update_settings", settings)
+		if _, err := host.wait("game_settings"); err != nil {
+			return clients, err
+		}
+	}
+
+	for i, c := range clients[1:] {
+		c.send("join_room", map[string]string{"join_code": room.Code, "name": fmt.Sprint("p", i+2)})
+		if _, err := c.wait("state_snapshot"); err != nil {
+			return clients, err
+		}
+	}
+	return clients, nil
+}
+
+func readyAll(clients []*testClient) {
+	for _, c := range clients {
+		c.send("set_ready", map[string]bool{"ready": true})
+	}
+}
+
+// play goes for every word on the board in random order, with a preview
+// before each selection, a wrong guess, a hint, some chat and a stray
+// create_room mixed in
+func (c *testClient) play(start Message) {
+	var game struct {
+		Board [][]rune `json:"board"`
+		Words []string `json:"words"`
+	}
+	json.Unmarshal(start.Payload, &game)
+
+	c.send("chat", map[string]string{"text": "good luck"})
+	c.send("create_room", map[string]string{"name": "renamed"}) // already seated, must not touch the room
+	c.send("select_word", map[string]interface{}{"start": server.Coord{Row: 0, Col: 0}, "end": server.Coord{Row: 0, Col: 1}})
+	c.send("request_hint", map[string]string{"kind": "first_letter"})
+
+	for _, i := range rand.Perm(len(game.Words)) {
+		from, to, ok := findWord(game.Board, strings.ToUpper(game.Words[i]))
+		if !ok {
+			continue
+		}
+		c.send("selection_preview", map[string]interface{}{"start": from, "end": to})
+		c.send("select_word", map[string]interface{}{"start": from, "end": to})
+	}
+}
+
+func findWord(board [][]rune, word string) (server.Coord, server.Coord, bool) {
+	letters := []rune(word)
+	n := len(board)
+	for row := 0; row < n; row++ {
+		for col := 0; col < n; col++ {
+			for dr := -1; dr <= 1; dr++ {
+				for dc := -1; dc <= 1; dc++ {
+					if dr == 0 && dc == 0 {
+						continue
+					}
+					last := len(letters) - 1
+					endRow, endCol := row + dr*last, col + dc*last
+					if endRow < 0 || endRow >= n || endCol < 0 || endCol >= n {
+						continue
+					}
+
+					found := true
+					for i, l := range letters {
+						if board[row + dr*i][col + dc*i] != l {
+							found = false
+							break
+						}
+					}
+					if found {
+						return server.Coord{Row: row, Col: col}, server.Coord{Row: endRow, Col: endCol}, true
+					}
+				}
+			}
+		}
+	}
+	return server.Coord{}, server.Coord{}, false
+}
+
+// playUntil plays every game that starts until one of the end messages arrives
+func (c *testClient) playUntil(end ...string) error {
+	for {
+		msg, err := c.wait(append([]string{"game_start"}, end...)...)
+		if err != nil {
+			return err
+		}
+		if msg.Type != "game_start" {
+			return nil
+		}
+		go c.play(msg)
+	}
+}
+
+// together runs fn for every client at once and returns the first error
+func together(clients []*testClient, fn func(i int, c *testClient) error) error {
+	errs := make(chan error, len(clients))
+	for i, c := range clients {
+		go func(i int, c *testClient) { errs <- fn(i, c) }(i, c)
+	}
+
+	var first error
+	for range clients {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Scenarios, each plays one room to the end
+var stressRooms = map[string]func(url string) ([]*testClient, error){
+	"series": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 2, map[string]interface{}{"series_length": 3})
+		if err != nil {
+			return clients, err
+		}
+		readyAll(clients)
+		return clients, together(clients, func(_ int, c *testClient) error { return c.playUntil("series_over") })
+	},
+	"free for all, twice": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 3, map[string]interface{}{"max_players": 3})
+		if err != nil {
+			return clients, err
+		}
+		for game := 0; game < 2; game++ {
+			readyAll(clients)
+			err := together(clients, func(_ int, c *testClient) error { return c.playUntil("game_over") })
+			if err != nil {
+				return clients, err
+			}
+		}
+		return clients, nil
+	},
+	"teams": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 4, map[string]interface{}{"max_players": 4, "mode": "teams"})
+		if err != nil {
+			return clients, err
+		}
+		for i, c := range clients {
+			c.send("set_team", map[string]int{"team": i%2 + 1})
+		}
+		readyAll(clients)
+		return clients, together(clients, func(_ int, c *testClient) error { return c.playUntil("game_over") })
+	},
+	"co-op out of time": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 2, map[string]interface{}{"mode": "coop", "time_limit": 1})
+		if err != nil {
+			return clients, err
+		}
+		readyAll(clients)
+		return clients, together(clients, func(_ int, c *testClient) error {
+			if _, err := c.wait("game_start"); err != nil {
+				return err
+			}
+			_, err := c.wait("game_over")
+			return err
+		})
+	},
+	"leave mid-game": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 2, nil)
+		if err != nil {
+			return clients, err
+		}
+		readyAll(clients)
+		return clients, together(clients, func(i int, c *testClient) error {
+			msg, err := c.wait("game_start")
+			if err != nil {
+				return err
+			}
+			if i == 1 {
+				return c.send("leave_room", nil)
+			}
+			go c.play(msg)
+			_, err = c.wait("player_left")
+			return err
+		})
+	},
+	"drop mid-game": func(url string) ([]*testClient, error) {
+		clients, err := openRoomAs([]string{signedURL(url), signedURL(url)}, nil)
+		if err != nil {
+			return clients, err
+		}
+		readyAll(clients)
+		return clients, together(clients, func(i int, c *testClient) error {
+			msg, err := c.wait("game_start")
+			if err != nil {
+				return err
+			}
+			if i == 1 {
+				c.close()
+				return nil
+			}
+			// the seat is held while the game goes on
+			if _, err := c.wait("player_disconnected"); err != nil {
+				return err
+			}
+			go c.play(msg)
+			_, err = c.wait("player_left") // once the reconnect grace runs out
+			return err
+		})
+	},
+	"anonymous drop mid-game": func(url string) ([]*testClient, error) {
+		clients, err := openRoom(url, 2, nil)
+		if err != nil {
+			return clients, err
+		}
+		readyAll(clients)
+		return clients, together(clients, func(i int, c *testClient) error {
+			if _, err := c.wait("game_start"); err != nil {
+				return err
+			}
+			if i == 1 {
+				c.close()
+				return nil
+			}
+			// no way back without a token, so no seat is held
+			msg, err := c.wait("player_disconnected", "game_over")
+			if err != nil {
+				return err
+			}
+			var over struct {
+				Abandoned bool `json:"abandoned"`
+			}
+			json.Unmarshal(msg.Payload, &over)
+			if msg.Type != "game_over" || !over.Abandoned {
+				return fmt.Errorf("got %s %s, want an abandoned game_over", msg.Type, msg.Payload)
+			}
+			return nil
+		})
+	},
+}
+
+// TestConcurrentRooms plays several rooms at once through real sockets, with
+// parallel selections, readying, leaving and timers. Run it with -race.
+func TestConcurrentRooms(t *testing.T) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	cfg := config.Default()
+	cfg.WordLists["default"] = "../../config/words.txt"
+	cfg.Limits.MaxConnectionsPerIP = 100
+	cfg.Connection.SendQueueSize = 256 // the bots play faster than 16 messages can drain
+	for msgType := range cfg.Limits.Messages {
+		cfg.Limits.Messages[msgType] = config.Rate{PerSecond: 1000, Burst: 1000}
+	}
+	cfg.Rooms.MinTimeLimit = 1
+	cfg.Rooms.SeriesIntermission.Duration = 20 * time.Millisecond
+	cfg.AntiCheat.MaxMisses = 1000
+	cfg.Auth.Secret = testSecret
+	cfg.Rooms.ReconnectGrace.Duration = 50 * time.Millisecond
+
+	gameServer, err := server.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(gameServer, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	const copies = 3
+	var wg sync.WaitGroup
+	errs := make(chan error, copies*len(stressRooms))
+	for i := 0; i < copies; i++ {
+		for name, play := range stressRooms {
+			wg.Add(1)
+			go func(name string, play func(string) ([]*testClient, error)) {
+				defer wg.Done()
+				clients, err := play(url)
+				for _, c := range clients {
+					c.close()
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s: %v", name, err)
+				}
+			}(name, play)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	// every socket is closed, the rooms should all go away
+	deadline := time.Now().Add(5 * time.Second)
+	for len(gameServer.Rooms()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d rooms left after everyone disconnected", len(gameServer.Rooms()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,8 +1,8 @@
-package websocket
-
-import "encoding/json"
-
-type Message struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload,omitempty"`
+package websocket
+
+import "encoding/json"
+
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
\ No newline at end of file
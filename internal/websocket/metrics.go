@@ -0,0 +1,10 @@
+package websocket
+
+import "github.com/Gexff/word-search-1v1-go-websocket-server/internal/metrics"
+
+var (
+	messagesIn = metrics.NewCounterVec("wordsearch_messages_in_total", "Messages read from players by type.", "type")
+	rateLimited = metrics.NewCounterVec("wordsearch_rate_limited_total", "Messages dropped by the rate limiter by type.", "type")
+	rejectedConnections = metrics.NewCounterVec("wordsearch_rejected_connections_total", "Upgrade requests turned away by reason.", "reason")
+	claimLatency = metrics.NewHistogram("wordsearch_claim_latency_seconds", "Time from reading select_word to the room answering it.", metrics.LatencyBuckets)
+)